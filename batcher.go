@@ -0,0 +1,268 @@
+package torch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBatcherClosed is returned by Batcher.Run after the batcher has been closed
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+// BatcherOptions configures a Batcher
+type BatcherOptions struct {
+	// MaxBatchSize is the maximum number of samples run at once (defaults to 32)
+	MaxBatchSize int
+	// MaxLatency is the maximum time the first sample of a batch waits for more samples (defaults to 5ms)
+	MaxLatency time.Duration
+	// QueueSize is the number of samples that can wait for a batch before Run blocks (defaults to 4 * MaxBatchSize)
+	QueueSize int
+}
+
+// Batcher groups single-sample inputs from many goroutines into batches, runs a JITModuleMethod
+// once per batch and splits the output back to each caller. Inputs are stacked along a new first
+// dimension so every sample must have the same shape; a failing batch fails all of its callers.
+type Batcher struct {
+	method  *JITModuleMethod
+	options BatcherOptions
+
+	// mutex guards closed so that no sample is queued after the loop has drained the queue
+	mutex    sync.RWMutex
+	closed   bool
+	requests chan *batchRequest
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type batchRequest struct {
+	ctx    context.Context
+	inputs []*Tensor
+	result chan batchResult
+}
+
+type batchResult struct {
+	value interface{}
+	err   error
+}
+
+// NewBatcher returns a new Batcher for given method and starts batching
+func NewBatcher(method *JITModuleMethod, options BatcherOptions) *Batcher {
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = 32
+	}
+	if options.MaxLatency <= 0 {
+		options.MaxLatency = 5 * time.Millisecond
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 4 * options.MaxBatchSize
+	}
+
+	b := &Batcher{
+		method:   method,
+		options:  options,
+		requests: make(chan *batchRequest, options.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.loop()
+
+	return b
+}
+
+// Run queues a single sample (one tensor per method argument, without the batch dimension) and
+// waits for its share of the batched output. Run blocks while the queue is full and returns
+// ctx.Err() if the context is done before the result is available.
+func (b *Batcher) Run(ctx context.Context, inputs ...*Tensor) (interface{}, error) {
+	req := &batchRequest{
+		ctx:    ctx,
+		inputs: inputs,
+		result: make(chan batchResult, 1),
+	}
+
+	if err := b.enqueue(req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-req.result:
+		return res.value, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Batcher) enqueue(req *batchRequest) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.closed {
+		return ErrBatcherClosed
+	}
+
+	select {
+	case b.requests <- req:
+		return nil
+	case <-req.ctx.Done():
+		return req.ctx.Err()
+	}
+}
+
+// Close stops accepting new samples and waits until queued samples have been processed
+func (b *Batcher) Close() {
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	b.mutex.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *Batcher) loop() {
+	defer b.wg.Done()
+
+	for {
+		var first *batchRequest
+		select {
+		case first = <-b.requests:
+		case <-b.done:
+			b.drain()
+			return
+		}
+
+		batch := []*batchRequest{first}
+		timer := time.NewTimer(b.options.MaxLatency)
+
+	collect:
+		for len(batch) < b.options.MaxBatchSize {
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			case <-b.done:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.process(batch)
+	}
+}
+
+// drain processes samples left in the queue after Close
+func (b *Batcher) drain() {
+	for {
+		batch := make([]*batchRequest, 0, b.options.MaxBatchSize)
+	collect:
+		for len(batch) < b.options.MaxBatchSize {
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			default:
+				break collect
+			}
+		}
+
+		if len(batch) == 0 {
+			return
+		}
+
+		b.process(batch)
+	}
+}
+
+func (b *Batcher) process(batch []*batchRequest) {
+	// Samples whose callers have already given up are not run
+	active := batch[:0]
+	for _, req := range batch {
+		if req.ctx.Err() == nil {
+			active = append(active, req)
+		}
+	}
+
+	if len(active) == 0 {
+		return
+	}
+
+	outputs, err := b.run(active)
+	for i, req := range active {
+		if err != nil {
+			req.result <- batchResult{err: err}
+		} else {
+			req.result <- batchResult{value: outputs[i]}
+		}
+	}
+}
+
+func (b *Batcher) run(batch []*batchRequest) ([]interface{}, error) {
+	numInputs := len(batch[0].inputs)
+	for _, req := range batch {
+		if len(req.inputs) != numInputs {
+			return nil, fmt.Errorf("batched samples have different number of inputs (%d and %d)", numInputs, len(req.inputs))
+		}
+	}
+
+	inputs := make([]interface{}, numInputs)
+	for i := range inputs {
+		tensors := make([]*Tensor, len(batch))
+		for j, req := range batch {
+			tensors[j] = req.inputs[i]
+		}
+
+		stacked, err := Stack(tensors, 0)
+		if err != nil {
+			return nil, fmt.Errorf("unable to batch input %d: %w", i, err)
+		}
+		inputs[i] = stacked
+	}
+
+	output, err := b.method.Run(inputs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitBatch(output, len(batch))
+}
+
+// splitBatch splits a batched method output (tensor or tuple of outputs) along the first dimension
+func splitBatch(output interface{}, size int) ([]interface{}, error) {
+	switch output := output.(type) {
+	case *Tensor:
+		tensors, err := Unbind(output, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(tensors) != size {
+			return nil, fmt.Errorf("batch of %d samples returned %d results", size, len(tensors))
+		}
+
+		res := make([]interface{}, size)
+		for i, t := range tensors {
+			res[i] = t
+		}
+		return res, nil
+	case Tuple:
+		res := make([]interface{}, size)
+		for i := range res {
+			res[i] = make(Tuple, len(output))
+		}
+
+		for j, elem := range output {
+			split, err := splitBatch(elem, size)
+			if err != nil {
+				return nil, err
+			}
+			for i := range res {
+				res[i].(Tuple)[j] = split[i]
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unable to split batched output of type %T", output)
+	}
+}
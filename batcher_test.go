@@ -0,0 +1,76 @@
+package torch
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const batchedScript = `
+def forward(a, b):
+	return (a + b, a * b)
+`
+
+func Test_Batcher(t *testing.T) {
+	module, err := CompileTorchScript(batchedScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := module.GetMethod("forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batcher := NewBatcher(method, BatcherOptions{MaxBatchSize: 4, MaxLatency: 10 * time.Millisecond})
+	defer batcher.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			a, _ := NewTensor([]float32{float32(i), 1})
+			b, _ := NewTensor([]float32{2, 3})
+
+			res, err := batcher.Run(context.Background(), a, b)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			tuple := res.(Tuple)
+			if !reflect.DeepEqual(tuple[0].(*Tensor).Value(), []float32{float32(i) + 2, 4}) {
+				t.Error("wrong sum", i, tuple[0].(*Tensor).Value())
+			}
+			if !reflect.DeepEqual(tuple[1].(*Tensor).Value(), []float32{float32(i) * 2, 3}) {
+				t.Error("wrong product", i, tuple[1].(*Tensor).Value())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_BatcherCancel(t *testing.T) {
+	module, _ := CompileTorchScript(batchedScript)
+	method, _ := module.GetMethod("forward")
+
+	batcher := NewBatcher(method, BatcherOptions{MaxBatchSize: 4, MaxLatency: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	a, _ := NewTensor([]float32{1, 2})
+	if _, err := batcher.Run(ctx, a, a); err != context.DeadlineExceeded {
+		t.Error("expected deadline exceeded error", err)
+	}
+
+	batcher.Close()
+
+	if _, err := batcher.Run(context.Background(), a, a); err != ErrBatcherClosed {
+		t.Error("expected closed error", err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/orktes/go-torch"
+)
+
+// tensorJSON is the explicit tensor encoding used when the dtype should not default to float32.
+// Missing dtypes and shapes are filled in and the tensor is decoded with Tensor.UnmarshalJSON, which
+// parses the data elements for the dtype (so that int64 values keep their precision).
+type tensorJSON struct {
+	DType string            `json:"dtype"`
+	Shape []int64           `json:"shape"`
+	Data  []json.RawMessage `json:"data"`
+}
+
+// decodeInputs decodes method inputs given either as a JSON array in argument order or as
+// an object keyed by argument name
+func decodeInputs(args []torch.JITModuleMethodArgument, raw json.RawMessage) ([]interface{}, error) {
+	values := make([]json.RawMessage, len(args))
+
+	switch trimmed := bytes.TrimSpace(raw); {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		var list []json.RawMessage
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, fmt.Errorf("invalid inputs: %w", err)
+		}
+		if len(list) != len(args) {
+			return nil, fmt.Errorf("expected %d inputs but got %d", len(args), len(list))
+		}
+		copy(values, list)
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &named); err != nil {
+			return nil, fmt.Errorf("invalid inputs: %w", err)
+		}
+		for i, arg := range args {
+			value, ok := named[arg.Name]
+			if !ok {
+				return nil, fmt.Errorf("missing input %s", arg.Name)
+			}
+			values[i] = value
+			delete(named, arg.Name)
+		}
+		for name := range named {
+			return nil, fmt.Errorf("unknown input %s", name)
+		}
+	default:
+		return nil, fmt.Errorf("inputs should be an array or an object")
+	}
+
+	inputs := make([]interface{}, len(args))
+	for i, arg := range args {
+		var err error
+		inputs[i], err = decodeValue(arg.TypeInfo, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid input %s: %w", arg.Name, err)
+		}
+	}
+
+	return inputs, nil
+}
+
+// decodeValue decodes a JSON value for a TorchScript type (Tensor, int, float, bool or a tuple of supported types)
+func decodeValue(typ torch.Type, raw json.RawMessage) (interface{}, error) {
+	switch typ := typ.(type) {
+	case torch.TupleType:
+		var list []json.RawMessage
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("expected an array for %s: %w", typ, err)
+		}
+		if len(list) != len(typ.Elems) {
+			return nil, fmt.Errorf("expected %d values for %s but got %d", len(typ.Elems), typ, len(list))
+		}
+
+		tuple := make(torch.Tuple, len(list))
+		for i, elemType := range typ.Elems {
+			var err error
+			tuple[i], err = decodeValue(elemType, list[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return tuple, nil
+	case torch.TensorType:
+		return decodeTensor(raw)
+	case torch.IntType:
+		var v int64
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case torch.FloatType:
+		var v float64
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case torch.BoolType:
+		var v bool
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	default:
+		return nil, fmt.Errorf("unsupported argument type %s", typ)
+	}
+}
+
+func decodeTensor(raw json.RawMessage) (*torch.Tensor, error) {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var explicit tensorJSON
+		if err := json.Unmarshal(trimmed, &explicit); err != nil {
+			return nil, err
+		}
+		if explicit.DType == "" {
+			explicit.DType = "float32"
+		}
+		if explicit.Shape == nil {
+			explicit.Shape = []int64{int64(len(explicit.Data))}
+		}
+
+		b, err := json.Marshal(explicit)
+		if err != nil {
+			return nil, err
+		}
+
+		tensor := &torch.Tensor{}
+		if err := tensor.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+		return tensor, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	shape, err := jsonShape(value)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float32, 0, numElements(shape))
+	data = flattenJSON(value, data)
+
+	return torch.NewTensorWithShape(data, shape, torch.Float)
+}
+
+// jsonShape returns the shape of nested JSON arrays of numbers
+func jsonShape(value interface{}) ([]int64, error) {
+	switch v := value.(type) {
+	case float64:
+		return []int64{}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return []int64{0}, nil
+		}
+
+		inner, err := jsonShape(v[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range v[1:] {
+			elemShape, err := jsonShape(elem)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(elemShape, inner) {
+				return nil, fmt.Errorf("tensor arrays should all have the same length")
+			}
+		}
+
+		return append([]int64{int64(len(v))}, inner...), nil
+	default:
+		return nil, fmt.Errorf("tensors should contain only numbers (got %T)", value)
+	}
+}
+
+func flattenJSON(value interface{}, data []float32) []float32 {
+	switch v := value.(type) {
+	case float64:
+		return append(data, float32(v))
+	case []interface{}:
+		for _, elem := range v {
+			data = flattenJSON(elem, data)
+		}
+	}
+	return data
+}
+
+func numElements(shape []int64) int64 {
+	n := int64(1)
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+// encodeValue converts a method output into a value encodable as JSON. Tuples become arrays
+// and tensors are encoded from Tensor.ValueE() (byte tensors as numbers instead of base64).
+func encodeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case *torch.Tensor:
+		val, err := v.ValueE()
+		if err != nil {
+			return nil, err
+		}
+		return encodeBytes(reflect.ValueOf(val)), nil
+	case torch.Tuple:
+		res := make([]interface{}, len(v))
+		for i, elem := range v {
+			var err error
+			res[i], err = encodeValue(elem)
+			if err != nil {
+				return nil, fmt.Errorf("output %d: %w", i, err)
+			}
+		}
+		return res, nil
+	default:
+		return v, nil
+	}
+}
+
+func encodeBytes(v reflect.Value) interface{} {
+	if v.Kind() != reflect.Slice {
+		return v.Interface()
+	}
+
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		res := make([]int, v.Len())
+		for i := range res {
+			res[i] = int(v.Index(i).Uint())
+		}
+		return res
+	}
+
+	if v.Type().Elem().Kind() == reflect.Slice {
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			res[i] = encodeBytes(v.Index(i))
+		}
+		return res
+	}
+
+	return v.Interface()
+}
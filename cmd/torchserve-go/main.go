@@ -0,0 +1,80 @@
+// Command torchserve-go serves TorchScript models over HTTP with JSON encoded inputs and outputs.
+//
+// Usage:
+//
+//	torchserve-go -addr :8080 -model resnet=resnet.pt -model bert=bert.pt
+//
+// Every method of a model is exposed at POST /v1/models/{name}/{method}. The request body is
+// {"inputs": [...]} with one value per method argument (or an object keyed by argument name).
+// Tensors are nested JSON arrays (float32) or objects {"dtype": "int64", "shape": [2, 2], "data": [1, 2, 3, 4]},
+// int, float and bool arguments are JSON numbers and booleans, and tuples are JSON arrays. The response
+// is {"outputs": ...} encoded the same way from Tensor.Value(). Requests canceled by the client
+// return status 499 and requests whose deadline expires return 504.
+// GET /v1/models and GET /v1/models/{name} describe the loaded models and their method signatures.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/orktes/go-torch"
+)
+
+// modelFlags collects repeated -model name=path flags
+type modelFlags map[string]string
+
+func (f modelFlags) String() string {
+	models := make([]string, 0, len(f))
+	for name, path := range f {
+		models = append(models, name+"="+path)
+	}
+	return strings.Join(models, ",")
+}
+
+func (f modelFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("model should be given as name=path (got %q)", value)
+	}
+	if _, ok := f[parts[0]]; ok {
+		return fmt.Errorf("model %s given more than once", parts[0])
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+func main() {
+	models := modelFlags{}
+	addr := flag.String("addr", ":8080", "address to listen on")
+	maxRequestBytes := flag.Int64("max-request-bytes", defaultMaxRequestBytes, "maximum size of request bodies in bytes")
+	flag.Var(models, "model", "TorchScript model to serve as name=path (can be repeated)")
+	flag.Parse()
+
+	if len(models) == 0 {
+		fmt.Fprintln(os.Stderr, "at least one -model is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	srv := newServer()
+	srv.maxRequestBytes = *maxRequestBytes
+	for name, path := range models {
+		module, err := torch.LoadJITModule(path)
+		if err != nil {
+			log.Fatalf("unable to load model %s from %s: %v", name, path, err)
+		}
+		methodNames, err := module.GetMethodNamesE()
+		if err != nil {
+			log.Fatalf("unable to read methods of model %s: %v", name, err)
+		}
+		srv.models[name] = module
+		log.Printf("loaded model %s from %s (methods: %s)", name, path, strings.Join(methodNames, ", "))
+	}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/orktes/go-torch"
+	"github.com/orktes/go-torch/serving"
+)
+
+const modelsPath = "/v1/models"
+
+// defaultMaxRequestBytes is the default limit of request body sizes
+const defaultMaxRequestBytes = 32 << 20
+
+// statusClientClosedRequest is the (nginx) status of requests canceled by the client
+const statusClientClosedRequest = 499
+
+// server routes requests to methods of loaded models
+type server struct {
+	models map[string]*torch.JITModule
+	// maxRequestBytes limits the size of request bodies
+	maxRequestBytes int64
+}
+
+func newServer() *server {
+	return &server{
+		models:          map[string]*torch.JITModule{},
+		maxRequestBytes: defaultMaxRequestBytes,
+	}
+}
+
+type inferRequest struct {
+	Inputs json.RawMessage `json:"inputs"`
+}
+
+type inferResponse struct {
+	Outputs interface{} `json:"outputs"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type modelMetadata struct {
+	Name    string           `json:"name"`
+	Methods []methodMetadata `json:"methods"`
+}
+
+type methodMetadata struct {
+	Name      string             `json:"name"`
+	Arguments []argumentMetadata `json:"arguments"`
+	Returns   []argumentMetadata `json:"returns"`
+}
+
+type argumentMetadata struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path != strings.Trim(modelsPath, "/") && !strings.HasPrefix(path, strings.Trim(modelsPath, "/")+"/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, strings.Trim(modelsPath, "/")), "/")[1:]
+	switch len(parts) {
+	case 0:
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		s.listModels(w)
+	case 1:
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		s.modelMetadata(w, parts[0])
+	case 2:
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		s.infer(w, r, parts[0], parts[1])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+	}
+}
+
+func (s *server) listModels(w http.ResponseWriter) {
+	names := make([]string, 0, len(s.models))
+	for name := range s.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	models := make([]modelMetadata, 0, len(names))
+	for _, name := range names {
+		metadata, err := s.metadata(name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		models = append(models, metadata)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"models": models})
+}
+
+func (s *server) modelMetadata(w http.ResponseWriter, name string) {
+	if _, ok := s.models[name]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("model %s not found", name))
+		return
+	}
+
+	metadata, err := s.metadata(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+func (s *server) metadata(name string) (modelMetadata, error) {
+	module := s.models[name]
+
+	metadata := modelMetadata{Name: name, Methods: []methodMetadata{}}
+	methodNames, err := module.GetMethodNamesE()
+	if err != nil {
+		return metadata, err
+	}
+
+	for _, methodName := range methodNames {
+		method, err := module.GetMethod(methodName)
+		if err != nil {
+			return metadata, err
+		}
+
+		args, err := serving.MethodInputs(method)
+		if err != nil {
+			return metadata, err
+		}
+		returns, err := method.ReturnsE()
+		if err != nil {
+			return metadata, err
+		}
+
+		metadata.Methods = append(metadata.Methods, methodMetadata{
+			Name:      methodName,
+			Arguments: argumentsMetadata(args),
+			Returns:   argumentsMetadata(returns),
+		})
+	}
+
+	return metadata, nil
+}
+
+func (s *server) infer(w http.ResponseWriter, r *http.Request, name, methodName string) {
+	module, ok := s.models[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("model %s not found", name))
+		return
+	}
+
+	method, err := module.GetMethod(methodName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("method %s not found in model %s", methodName, name))
+		return
+	}
+
+	var req inferRequest
+	body := http.MaxBytesReader(w, r.Body, s.maxRequestBytes)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	args, err := serving.MethodInputs(method)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	inputs, err := decodeInputs(args, req.Inputs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := method.RunContext(r.Context(), inputs...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, err)
+		return
+	} else if errors.Is(err, context.Canceled) {
+		writeError(w, statusClientClosedRequest, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	outputs, err := encodeValue(res)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, inferResponse{Outputs: outputs})
+}
+
+func argumentsMetadata(args []torch.JITModuleMethodArgument) []argumentMetadata {
+	metadata := make([]argumentMetadata, len(args))
+	for i, arg := range args {
+		metadata[i] = argumentMetadata{Name: arg.Name, Type: arg.Type}
+	}
+	return metadata
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeJSON writes v as the response. The response is encoded before the status is written so that
+// values which can not be encoded (e.g. NaN outputs) are reported as errors instead of truncated responses.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		json.NewEncoder(&buf).Encode(errorResponse{Error: fmt.Sprintf("unable to encode response: %v", err)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
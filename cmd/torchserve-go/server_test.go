@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orktes/go-torch"
+)
+
+const testScript = `
+def sum(a, b):
+	return a + b
+
+def sum_sub(tup : Tuple[Tensor, Tensor]):
+	a, b = tup
+	return (a + b, a - b)
+
+def half(a):
+	return a.half()
+`
+
+func newTestServer(t *testing.T) *httptest.Server {
+	module, err := torch.CompileTorchScript(testScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newServer()
+	srv.models["test"] = module
+
+	return httptest.NewServer(srv)
+}
+
+func post(t *testing.T, url, body string) (int, map[string]interface{}) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, res
+}
+
+func Test_Infer(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	status, res := post(t, ts.URL+"/v1/models/test/sum", `{"inputs": [[[1, 2], [3, 4]], [[1, 1], [1, 1]]]}`)
+	if status != http.StatusOK {
+		t.Fatal("wrong status", status, res)
+	}
+	if !reflect.DeepEqual(res["outputs"], []interface{}{[]interface{}{2.0, 3.0}, []interface{}{4.0, 5.0}}) {
+		t.Error("wrong outputs", res["outputs"])
+	}
+
+	status, res = post(t, ts.URL+"/v1/models/test/sum", `{"inputs": {"a": {"dtype": "int64", "shape": [2], "data": [1, 2]}, "b": {"dtype": "int64", "data": [3, 4]}}}`)
+	if status != http.StatusOK {
+		t.Fatal("wrong status", status, res)
+	}
+	if !reflect.DeepEqual(res["outputs"], []interface{}{4.0, 6.0}) {
+		t.Error("wrong outputs", res["outputs"])
+	}
+
+	status, res = post(t, ts.URL+"/v1/models/test/sum_sub", `{"inputs": [[[3, 4], [1, 2]]]}`)
+	if status != http.StatusOK {
+		t.Fatal("wrong status", status, res)
+	}
+	if !reflect.DeepEqual(res["outputs"], []interface{}{[]interface{}{4.0, 6.0}, []interface{}{2.0, 2.0}}) {
+		t.Error("wrong outputs", res["outputs"])
+	}
+}
+
+func Test_InferErrors(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/v1/models/missing/sum", `{"inputs": []}`, http.StatusNotFound},
+		{"/v1/models/test/missing", `{"inputs": []}`, http.StatusNotFound},
+		{"/v1/models/test/sum", `{"inputs": [[1, 2]]}`, http.StatusBadRequest},
+		{"/v1/models/test/sum", `{"inputs": [[1, 2], [[1], 2]]}`, http.StatusBadRequest},
+		{"/v1/models/test/sum", `{"inputs": {"a": [1], "c": [1]}}`, http.StatusBadRequest},
+		{"/v1/models/test/sum", `not json`, http.StatusBadRequest},
+		{"/v1/models/test/sum", `{"inputs": [[1, 2], [1, 2, 3]]}`, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		status, res := post(t, ts.URL+test.path, test.body)
+		if status != test.status {
+			t.Error("wrong status for", test.path, test.body, status, res)
+		}
+		if res["error"] == nil {
+			t.Error("error message missing for", test.path, test.body)
+		}
+	}
+}
+
+func Test_InferRequestTooLarge(t *testing.T) {
+	module, err := torch.CompileTorchScript(testScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newServer()
+	srv.models["test"] = module
+	srv.maxRequestBytes = 16
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	status, res := post(t, ts.URL+"/v1/models/test/sum", `{"inputs": [[1, 2], [1, 2]]}`)
+	if status != http.StatusBadRequest || res["error"] == nil {
+		t.Error("request bodies over the limit should be rejected", status, res)
+	}
+}
+
+func Test_InferLongPrecision(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// 2^53 + 1 can not be represented as a float64
+	body := `{"inputs": [{"dtype": "int64", "data": [9007199254740993]}, {"dtype": "int64", "data": [0]}]}`
+	resp, err := http.Post(ts.URL+"/v1/models/test/sum", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Outputs []json.Number `json:"outputs"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || len(res.Outputs) != 1 || res.Outputs[0] != "9007199254740993" {
+		t.Error("int64 inputs should keep their precision", resp.StatusCode, res.Outputs)
+	}
+}
+
+func Test_InferContextErrors(t *testing.T) {
+	module, err := torch.CompileTorchScript(testScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newServer()
+	srv.models["test"] = module
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	for _, test := range []struct {
+		ctx    context.Context
+		status int
+	}{
+		{canceled, statusClientClosedRequest},
+		{expired, http.StatusGatewayTimeout},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/models/test/sum", strings.NewReader(`{"inputs": [[1], [2]]}`))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req.WithContext(test.ctx))
+
+		if rec.Code != test.status {
+			t.Error("wrong status", rec.Code, test.status, rec.Body.String())
+		}
+	}
+}
+
+func Test_InferUnencodableOutput(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// float32 overflows to +Inf which can not be encoded as JSON
+	status, res := post(t, ts.URL+"/v1/models/test/sum", `{"inputs": [[3e38], [3e38]]}`)
+	if status != http.StatusInternalServerError || res["error"] == nil {
+		t.Error("outputs which can not be encoded should return an error", status, res)
+	}
+}
+
+func Test_InferUnsupportedOutput(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	status, res := post(t, ts.URL+"/v1/models/test/half", `{"inputs": [[1, 2]]}`)
+	if status != http.StatusInternalServerError || res["error"] == nil {
+		t.Error("outputs of unsupported types should return an error", status, res)
+	}
+}
+
+func Test_ModelMetadata(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/models/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var metadata modelMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Name != "test" || len(metadata.Methods) != 3 {
+		t.Fatal("wrong metadata", metadata)
+	}
+
+	for _, method := range metadata.Methods {
+		if method.Name == "sum" && !reflect.DeepEqual(method.Arguments, []argumentMetadata{{"a", "Tensor"}, {"b", "Tensor"}}) {
+			t.Error("wrong arguments", method.Arguments)
+		}
+	}
+}
+
+func Test_DecodeNestedTuple(t *testing.T) {
+	typ, err := torch.ParseType("Tuple[Tensor, Tuple[int, bool]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := decodeValue(typ, json.RawMessage(`[[1, 2], [3, true]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tuple, ok := value.(torch.Tuple)
+	if !ok || len(tuple) != 2 || !reflect.DeepEqual(tuple[1], torch.Tuple{int64(3), true}) {
+		t.Error("wrong value", value)
+	}
+
+	if _, err := decodeValue(typ, json.RawMessage(`[[1, 2]]`)); err == nil {
+		t.Error("should return an error for tuples of the wrong length")
+	}
+}
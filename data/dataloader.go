@@ -0,0 +1,292 @@
+// Package data provides datasets and batching for evaluation and training loops
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+
+	"github.com/orktes/go-torch"
+)
+
+// Dataset is a collection of samples accessed by index
+type Dataset interface {
+	// Len returns the number of samples
+	Len() int
+	// Get returns the sample at given index (*torch.Tensor, torch.Tuple or a Go value accepted by torch.NewTensor)
+	Get(index int) (interface{}, error)
+}
+
+// CollateFunc merges a list of samples into a batch
+type CollateFunc func(samples []interface{}) (interface{}, error)
+
+// DefaultCollate stacks tensors along a new first dimension. Tuples are collated element-wise
+// into a Tuple of batches and Go values are converted into tensors before stacking. The result
+// can be passed to JITModuleMethod.Run.
+func DefaultCollate(samples []interface{}) (interface{}, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples to collate")
+	}
+
+	switch first := samples[0].(type) {
+	case *torch.Tensor:
+		tensors := make([]*torch.Tensor, len(samples))
+		for i, s := range samples {
+			t, ok := s.(*torch.Tensor)
+			if !ok {
+				return nil, fmt.Errorf("sample %d is %T but expected *torch.Tensor", i, s)
+			}
+			tensors[i] = t
+		}
+		return torch.Stack(tensors, 0)
+	case torch.Tuple:
+		batch := make(torch.Tuple, len(first))
+		for j := range first {
+			elems := make([]interface{}, len(samples))
+			for i, s := range samples {
+				tuple, ok := s.(torch.Tuple)
+				if !ok || len(tuple) != len(first) {
+					return nil, fmt.Errorf("sample %d does not match the tuple structure of the first sample", i)
+				}
+				elems[i] = tuple[j]
+			}
+
+			var err error
+			batch[j], err = DefaultCollate(elems)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return batch, nil
+	default:
+		tensors := make([]interface{}, len(samples))
+		for i, s := range samples {
+			if reflect.TypeOf(s) != reflect.TypeOf(first) {
+				return nil, fmt.Errorf("sample %d is %T but expected %T", i, s, first)
+			}
+			t, err := torch.NewTensor(s)
+			if err != nil {
+				return nil, err
+			}
+			tensors[i] = t
+		}
+		return DefaultCollate(tensors)
+	}
+}
+
+// DataLoader iterates over a dataset in batches
+type DataLoader struct {
+	Dataset   Dataset
+	BatchSize int
+	// Shuffle shuffles samples every epoch. Shuffling is deterministic for a given Seed.
+	Shuffle bool
+	Seed    int64
+	// DropLast drops the last batch if it is smaller than BatchSize
+	DropLast bool
+	// Workers number of batches loaded concurrently (defaults to 1)
+	Workers int
+	// Prefetch number of batches loaded ahead of the consumer (defaults to Workers)
+	Prefetch int
+	// Collate merges samples into a batch (defaults to DefaultCollate)
+	Collate CollateFunc
+
+	mutex sync.Mutex
+	epoch int64
+}
+
+// NewDataLoader returns a DataLoader for given dataset and batch size
+func NewDataLoader(dataset Dataset, batchSize int) *DataLoader {
+	return &DataLoader{Dataset: dataset, BatchSize: batchSize}
+}
+
+// Len returns the number of batches in an epoch
+func (d *DataLoader) Len() int {
+	n := d.Dataset.Len()
+	if d.DropLast {
+		return n / d.batchSize()
+	}
+	return (n + d.batchSize() - 1) / d.batchSize()
+}
+
+func (d *DataLoader) batchSize() int {
+	if d.BatchSize <= 0 {
+		return 1
+	}
+	return d.BatchSize
+}
+
+func (d *DataLoader) batchIndices() [][]int {
+	d.mutex.Lock()
+	epoch := d.epoch
+	d.epoch++
+	d.mutex.Unlock()
+
+	n := d.Dataset.Len()
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+
+	if d.Shuffle {
+		r := rand.New(rand.NewSource(d.Seed + epoch))
+		r.Shuffle(n, func(i, j int) {
+			indices[i], indices[j] = indices[j], indices[i]
+		})
+	}
+
+	batchSize := d.batchSize()
+	batches := make([][]int, 0, d.Len())
+	for start := 0; start < n; start += batchSize {
+		end := start + batchSize
+		if end > n {
+			if d.DropLast {
+				break
+			}
+			end = n
+		}
+		batches = append(batches, indices[start:end])
+	}
+
+	return batches
+}
+
+func (d *DataLoader) load(indices []int) (interface{}, error) {
+	samples := make([]interface{}, len(indices))
+	for i, index := range indices {
+		var err error
+		samples[i], err = d.Dataset.Get(index)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get sample %d: %w", index, err)
+		}
+	}
+
+	collate := d.Collate
+	if collate == nil {
+		collate = DefaultCollate
+	}
+
+	return collate(samples)
+}
+
+type batchResult struct {
+	batch interface{}
+	err   error
+}
+
+type batchJob struct {
+	indices []int
+	result  chan batchResult
+}
+
+// Iter starts a new epoch and returns an iterator over its batches. Batches are returned in order.
+func (d *DataLoader) Iter() *Iterator {
+	workers := d.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	prefetch := d.Prefetch
+	if prefetch <= 0 {
+		prefetch = workers
+	}
+
+	it := &Iterator{
+		pending: make(chan chan batchResult, prefetch),
+		done:    make(chan struct{}),
+	}
+
+	jobs := make(chan batchJob)
+	go func() {
+		defer close(it.pending)
+		defer close(jobs)
+
+		for _, indices := range d.batchIndices() {
+			result := make(chan batchResult, 1)
+			select {
+			case it.pending <- result:
+			case <-it.done:
+				return
+			}
+
+			select {
+			case jobs <- batchJob{indices: indices, result: result}:
+			case <-it.done:
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				batch, err := d.load(job.indices)
+				job.result <- batchResult{batch: batch, err: err}
+			}
+		}()
+	}
+
+	return it
+}
+
+// Iterator iterates over batches of a single epoch
+type Iterator struct {
+	pending chan chan batchResult
+	done    chan struct{}
+	once    sync.Once
+
+	batch interface{}
+	err   error
+}
+
+// Next waits for the next batch and returns false when there are no more batches or an error occurred
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	select {
+	case <-it.done:
+		return false
+	default:
+	}
+
+	result, ok := <-it.pending
+	if !ok {
+		return false
+	}
+
+	// The job of a pending result is never sent to a worker if Close is called meanwhile
+	var res batchResult
+	select {
+	case res = <-result:
+	case <-it.done:
+		return false
+	}
+
+	if res.err != nil {
+		it.err = res.err
+		it.Close()
+		return false
+	}
+
+	it.batch = res.batch
+	return true
+}
+
+// Batch returns the current batch (*torch.Tensor or torch.Tuple with the default collate function)
+func (it *Iterator) Batch() interface{} {
+	return it.batch
+}
+
+// Err returns the error that stopped the iteration
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close stops loading batches. It should be called if the iteration is stopped before Next returns false.
+func (it *Iterator) Close() {
+	it.once.Do(func() {
+		close(it.done)
+	})
+}
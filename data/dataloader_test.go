@@ -0,0 +1,169 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/orktes/go-torch"
+)
+
+type rangeDataset int
+
+func (d rangeDataset) Len() int {
+	return int(d)
+}
+
+func (d rangeDataset) Get(index int) (interface{}, error) {
+	return torch.Tuple{[]float32{float32(index), float32(index)}, int64(index)}, nil
+}
+
+type failingDataset struct {
+	rangeDataset
+}
+
+func (d failingDataset) Get(index int) (interface{}, error) {
+	if index == 3 {
+		return nil, errors.New("broken sample")
+	}
+	return d.rangeDataset.Get(index)
+}
+
+// blockingDataset blocks getting samples from index 1 on until unblock is closed
+type blockingDataset struct {
+	rangeDataset
+	unblock chan struct{}
+}
+
+func (d blockingDataset) Get(index int) (interface{}, error) {
+	if index >= 1 {
+		<-d.unblock
+	}
+	return d.rangeDataset.Get(index)
+}
+
+func labels(t *testing.T, loader *DataLoader) [][]int64 {
+	var res [][]int64
+
+	it := loader.Iter()
+	defer it.Close()
+
+	for it.Next() {
+		batch := it.Batch().(torch.Tuple)
+		res = append(res, batch.Get(1).(*torch.Tensor).Value().([]int64))
+	}
+
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func Test_DataLoader(t *testing.T) {
+	loader := NewDataLoader(rangeDataset(5), 2)
+	loader.Workers = 3
+
+	if loader.Len() != 3 {
+		t.Error("wrong number of batches", loader.Len())
+	}
+
+	expected := [][]int64{{0, 1}, {2, 3}, {4}}
+	if res := labels(t, loader); !reflect.DeepEqual(res, expected) {
+		t.Error("wrong batches", res)
+	}
+
+	it := loader.Iter()
+	it.Next()
+	features := it.Batch().(torch.Tuple).Get(0).(*torch.Tensor)
+	if shape := features.Shape(); shape[0] != 2 || shape[1] != 2 {
+		t.Error("wrong feature batch shape", shape)
+	}
+	it.Close()
+
+	if it.Next() {
+		t.Error("closed iterator should not return batches")
+	}
+}
+
+func Test_DataLoaderDropLast(t *testing.T) {
+	loader := NewDataLoader(rangeDataset(5), 2)
+	loader.DropLast = true
+
+	if loader.Len() != 2 {
+		t.Error("wrong number of batches", loader.Len())
+	}
+
+	if res := labels(t, loader); len(res) != 2 {
+		t.Error("wrong batches", res)
+	}
+}
+
+func Test_DataLoaderShuffle(t *testing.T) {
+	newLoader := func() *DataLoader {
+		loader := NewDataLoader(rangeDataset(20), 20)
+		loader.Shuffle = true
+		loader.Seed = 42
+		return loader
+	}
+
+	a, b := newLoader(), newLoader()
+
+	first := labels(t, a)
+	if !reflect.DeepEqual(first, labels(t, b)) {
+		t.Error("shuffling should be deterministic for a seed")
+	}
+
+	if reflect.DeepEqual(first, labels(t, a)) {
+		t.Error("every epoch should be shuffled differently")
+	}
+}
+
+func Test_DataLoaderError(t *testing.T) {
+	loader := NewDataLoader(failingDataset{rangeDataset(6)}, 2)
+	loader.Workers = 2
+
+	it := loader.Iter()
+	defer it.Close()
+
+	batches := 0
+	for it.Next() {
+		batches++
+	}
+
+	if batches != 1 {
+		t.Error("iteration should stop at the failing batch", batches)
+	}
+
+	if it.Err() == nil {
+		t.Error("should return an error")
+	}
+}
+
+func Test_DataLoaderCloseWhileWaiting(t *testing.T) {
+	dataset := blockingDataset{rangeDataset(4), make(chan struct{})}
+	defer close(dataset.unblock)
+
+	it := NewDataLoader(dataset, 1).Iter()
+	if !it.Next() {
+		t.Fatal("first batch should be loaded", it.Err())
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- it.Next()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	it.Close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Next should return false after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next should return when the iterator is closed")
+	}
+}
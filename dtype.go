@@ -2,7 +2,10 @@ package torch
 
 // #include "torch.hpp"
 import "C"
-import "reflect"
+import (
+	"reflect"
+	"strconv"
+)
 
 // DType tensor scalar data type
 type DType C.Torch_DataType
@@ -22,6 +25,25 @@ const (
 	Double DType = C.Torch_Double
 )
 
+var dtypeStrings = map[DType]string{
+	Byte:                 "uint8",
+	Char:                 "int8",
+	DType(C.Torch_Short): "int16",
+	Int:                  "int32",
+	Long:                 "int64",
+	DType(C.Torch_Half):  "float16",
+	Float:                "float32",
+	Double:               "float64",
+}
+
+// String returns the name of the data type as in PyTorch (e.g. float32)
+func (dt DType) String() string {
+	if name, ok := dtypeStrings[dt]; ok {
+		return name
+	}
+	return "DType(" + strconv.Itoa(int(dt)) + ")"
+}
+
 var types = []struct {
 	typ      reflect.Type
 	dataType C.Torch_DataType
@@ -35,3 +57,61 @@ var types = []struct {
 	{reflect.TypeOf(float32(0)), C.Torch_Float},
 	{reflect.TypeOf(float64(0)), C.Torch_Double},
 }
+
+// npyTypes maps NumPy type kinds and sizes (e.g. '<f4') to tensor data types
+var npyTypes = []struct {
+	kind     byte
+	size     int
+	dataType DType
+}{
+	{'u', 1, Byte},
+	{'i', 1, Char},
+	{'i', 4, Int},
+	{'i', 8, Long},
+	{'f', 4, Float},
+	{'f', 8, Double},
+}
+
+// torchStorageTypes maps the storage classes of torch.save archives (e.g. torch.FloatStorage) to tensor data types
+var torchStorageTypes = []struct {
+	name     string
+	dataType DType
+}{
+	{"ByteStorage", Byte},
+	{"CharStorage", Char},
+	{"IntStorage", Int},
+	{"LongStorage", Long},
+	{"FloatStorage", Float},
+	{"DoubleStorage", Double},
+}
+
+func torchStorageType(dt DType) (string, bool) {
+	for _, t := range torchStorageTypes {
+		if t.dataType == dt {
+			return t.name, true
+		}
+	}
+	return "", false
+}
+
+func torchStorageDType(name string) (DType, bool) {
+	for _, t := range torchStorageTypes {
+		if t.name == name {
+			return t.dataType, true
+		}
+	}
+	return 0, false
+}
+
+// safetensorsTypes maps safetensors dtype names to tensor data types
+var safetensorsTypes = []struct {
+	name     string
+	dataType DType
+}{
+	{"U8", Byte},
+	{"I8", Char},
+	{"I32", Int},
+	{"I64", Long},
+	{"F32", Float},
+	{"F64", Double},
+}
@@ -4,25 +4,167 @@ package torch
 // #include <stdlib.h>
 import "C"
 import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
+// ErrorKind categorizes errors returned by torch functions. Kinds can be matched with
+// errors.Is (e.g. errors.Is(err, torch.ShapeMismatchError)).
+type ErrorKind int
+
+const (
+	// UnknownError is any error which is not categorized
+	UnknownError ErrorKind = iota
+	// ShapeMismatchError is returned when tensor shapes or sizes do not match
+	ShapeMismatchError
+	// DTypeMismatchError is returned when tensor data types do not match
+	DTypeMismatchError
+	// IndexError is returned when an index or dimension is out of range
+	IndexError
+	// CompileError is returned when TorchScript can not be compiled
+	CompileError
+	// FileNotFoundError is returned when a file can not be opened (it also matches os.ErrNotExist)
+	FileNotFoundError
+	// UnsupportedTypeError is returned for values and types which are not supported
+	UnsupportedTypeError
+)
+
+var errorKindNames = map[ErrorKind]string{
+	UnknownError:         "unknown error",
+	ShapeMismatchError:   "shape mismatch",
+	DTypeMismatchError:   "dtype mismatch",
+	IndexError:           "index error",
+	CompileError:         "compile error",
+	FileNotFoundError:    "file not found",
+	UnsupportedTypeError: "unsupported type",
+}
+
+func (k ErrorKind) Error() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return "error kind " + strconv.Itoa(int(k))
+}
+
+// errorPatterns categorize LibTorch error messages (matched in order)
+var errorPatterns = []struct {
+	kind    ErrorKind
+	pattern *regexp.Regexp
+}{
+	{FileNotFoundError, regexp.MustCompile(`(?i)open file failed|no such file|file not found|cannot open file|could not open`)},
+	{IndexError, regexp.MustCompile(`(?i)out of range|out of bounds|index \S+ is out`)},
+	{DTypeMismatchError, regexp.MustCompile(`(?i)scalar type|dtype|can't be cast to the desired output type`)},
+	{ShapeMismatchError, regexp.MustCompile(`(?i)size mismatch|must match the size|shapes cannot be multiplied|is invalid for input of size|sizes of tensors must match|shape mismatch`)},
+	{UnsupportedTypeError, regexp.MustCompile(`(?i)unsupported|not supported`)},
+}
+
 // Error errors returned by torch functions
 type Error struct {
-	message string
+	// Kind categorizes the error
+	Kind ErrorKind
+	// Message is the error message without the C++ backtrace
+	Message string
+	// Backtrace is the C++ backtrace recorded by LibTorch (empty if none was recorded)
+	Backtrace string
+	// Line and Column locate compile errors in the TorchScript source (zero if not known)
+	Line, Column int
 }
 
 func (te *Error) Error() string {
-	return te.message
+	return te.Message
+}
+
+// Is reports whether the error is of the given ErrorKind. FileNotFoundError also matches os.ErrNotExist.
+func (te *Error) Is(target error) bool {
+	if kind, ok := target.(ErrorKind); ok {
+		return te.Kind == kind
+	}
+	return target == os.ErrNotExist && te.Kind == FileNotFoundError
+}
+
+func newError(kind ErrorKind, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
 }
 
 func checkError(err C.Torch_Error) *Error {
 	if err.message != nil {
 		defer C.free(unsafe.Pointer(err.message))
-		return &Error{
-			message: C.GoString(err.message),
+
+		te := &Error{
+			Message: C.GoString(err.message),
+		}
+		if err.backtrace != nil {
+			defer C.free(unsafe.Pointer(err.backtrace))
+			te.Backtrace = strings.TrimSpace(C.GoString(err.backtrace))
 		}
+
+		for _, p := range errorPatterns {
+			if p.pattern.MatchString(te.Message) {
+				te.Kind = p.kind
+				break
+			}
+		}
+
+		return te
 	}
 
 	return nil
 }
+
+var (
+	// compileErrorLocation matches locations printed as "at <string>:3:5" or `File "<string>", line 3`
+	compileErrorLocation = regexp.MustCompile(`at \S+:(\d+):(\d+)|line (\d+)`)
+	// compileErrorMarker matches the line marking the error in the highlighted source
+	compileErrorMarker = regexp.MustCompile(`(?m)^( *)~+.*<--- HERE`)
+)
+
+// compileError marks an error returned when compiling TorchScript as a CompileError and locates it in the source
+func compileError(err *Error, source string) *Error {
+	err.Kind = CompileError
+
+	if m := compileErrorLocation.FindStringSubmatch(err.Message); m != nil {
+		if m[1] != "" {
+			err.Line, _ = strconv.Atoi(m[1])
+			err.Column, _ = strconv.Atoi(m[2])
+			return err
+		}
+		err.Line, _ = strconv.Atoi(m[3])
+	}
+
+	// Column is the position of the highlighted range (the marker is aligned with the source line above it)
+	if m := compileErrorMarker.FindStringSubmatch(err.Message); m != nil {
+		err.Column = len(m[1]) + 1
+
+		if err.Line == 0 {
+			// Older versions print only the highlighted line, so find it in the source
+			lines := strings.Split(err.Message[:strings.Index(err.Message, m[0])], "\n")
+			if len(lines) >= 2 {
+				highlighted := lines[len(lines)-2]
+				for i, line := range strings.Split(source, "\n") {
+					if line == highlighted {
+						err.Line = i + 1
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return err
+}
+
+// setFailpoint makes the next call of given bridge function (e.g. "Torch_TensorGrad") fail so that
+// tests can provoke errors LibTorch does not return otherwise. An empty name disarms the failpoint.
+func setFailpoint(name string) {
+	cstr := C.CString(name)
+	defer C.free(unsafe.Pointer(cstr))
+
+	C.Torch_SetFailpoint(cstr)
+}
@@ -0,0 +1,211 @@
+package torch
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func Test_ErrorKinds(t *testing.T) {
+	module, err := CompileTorchScript(`
+def add(a, b):
+	return a + b
+
+def mm(a, b):
+	return a.mm(b)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := NewTensor([]float32{1, 2})
+	b, _ := NewTensor([]float32{1, 2, 3})
+	matrix, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	longMatrix, _ := NewTensor([][]int64{{1, 2}, {3, 4}})
+
+	_, loadErr := LoadJITModule("does_not_exist.pt")
+	_, addErr := module.RunMethod("add", a, b)
+	_, mmErr := module.RunMethod("mm", matrix, longMatrix)
+	_, unbindErr := Unbind(a, 3)
+	_, tensorErr := NewTensor([]string{"a"})
+	_, runErr := module.RunMethod("add", a, "b")
+
+	cases := []struct {
+		name string
+		err  error
+		kind ErrorKind
+	}{
+		{"load", loadErr, FileNotFoundError},
+		{"add", addErr, ShapeMismatchError},
+		{"mm", mmErr, DTypeMismatchError},
+		{"unbind", unbindErr, IndexError},
+		{"tensor", tensorErr, UnsupportedTypeError},
+		{"run", runErr, UnsupportedTypeError},
+	}
+
+	for _, c := range cases {
+		var torchErr *Error
+		if !errors.As(c.err, &torchErr) {
+			t.Errorf("%s: expected a torch error but got %v", c.name, c.err)
+			continue
+		}
+		if !errors.Is(c.err, c.kind) || torchErr.Kind != c.kind {
+			t.Errorf("%s: expected %v but got %v (%v)", c.name, c.kind, torchErr.Kind, c.err)
+		}
+	}
+
+	if !errors.Is(loadErr, os.ErrNotExist) {
+		t.Error("file not found errors should match os.ErrNotExist")
+	}
+	if errors.Is(addErr, os.ErrNotExist) || errors.Is(addErr, IndexError) {
+		t.Error("shape mismatch should not match other errors")
+	}
+}
+
+func Test_ScriptCompileError(t *testing.T) {
+	_, err := CompileTorchScript(`
+def add(a, b):
+	return a + c
+`)
+
+	var torchErr *Error
+	if !errors.As(err, &torchErr) || !errors.Is(err, CompileError) {
+		t.Fatal("expected a compile error but got", err)
+	}
+	if torchErr.Line != 3 || torchErr.Column == 0 {
+		t.Errorf("wrong location %d:%d for %v", torchErr.Line, torchErr.Column, err)
+	}
+}
+
+func Test_CompileErrorLocation(t *testing.T) {
+	source := "\ndef add(a, b):\n    return a + c\n"
+
+	cases := []struct {
+		message      string
+		line, column int
+	}{
+		{
+			"\nundefined value c:\n@torch.jit.script\ndef add(a, b):\n    return a + c\n               ~ <--- HERE\n",
+			3, 16,
+		},
+		{
+			"undefined value c:\n  File \"<string>\", line 3\ndef add(a, b):\n    return a + c\n               ~ <--- HERE\n",
+			3, 16,
+		},
+		{
+			"undefined value c:\nat <string>:3:16\ndef add(a, b):\n    return a + c\n               ~ <--- HERE\n",
+			3, 16,
+		},
+		{"expected an indented block", 0, 0},
+	}
+
+	for _, c := range cases {
+		err := compileError(&Error{Message: c.message}, source)
+		if err.Kind != CompileError || err.Line != c.line || err.Column != c.column {
+			t.Errorf("expected %d:%d but got %d:%d for %q", c.line, c.column, err.Line, err.Column, c.message)
+		}
+	}
+}
+
+func Test_StateDictErrorIs(t *testing.T) {
+	err := error(&StateDictError{Mismatched: []ShapeMismatch{{Name: "weight"}}})
+	if !errors.Is(err, ShapeMismatchError) {
+		t.Error("state dict error with mismatched shapes should match ShapeMismatchError")
+	}
+
+	err = &StateDictError{Missing: []string{"weight"}}
+	if errors.Is(err, ShapeMismatchError) {
+		t.Error("state dict error without mismatched shapes should not match ShapeMismatchError")
+	}
+}
+
+func Test_Failpoints(t *testing.T) {
+	module, err := CompileTorchScript(defaultsScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, err := module.GetMethod("generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestParameter(t)
+	opt, err := NewSGD([]*Tensor{w}, SGDOptions{LearningRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linear, err := NewLinear(2, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "optimizer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // clean up
+
+	defer setFailpoint("")
+
+	cases := []struct {
+		function string
+		call     func() error
+	}{
+		{"Torch_JITModuleGetMethodNames", func() error { _, err := module.GetMethodNamesE(); return err }},
+		{"Torch_JITModuleMethodArguments", func() error { _, err := method.ArgumentsE(); return err }},
+		{"Torch_JITModuleMethodReturns", func() error { _, err := method.ReturnsE(); return err }},
+		{"Torch_PrintTensors", func() error { return PrintTensors(w) }},
+		{"Torch_TensorGrad", func() error { _, err := w.GradE(); return err }},
+		{"Torch_OptimizerZeroGrad", opt.ZeroGrad},
+		{"Torch_TensorRequiresGrad", func() error { _, err := w.RequiresGradE(); return err }},
+		{"Torch_NNModuleNamedParameters", func() error { _, err := linear.ParametersE(); return err }},
+		{"Torch_NNModuleNamedParameters", func() error { _, err := linear.NamedParametersE(); return err }},
+		{"Torch_NNModuleTrain", func() error { return linear.TrainE(false) }},
+		{"Torch_OptimizerLearningRate", func() error { _, err := opt.LearningRateE(); return err }},
+		{"Torch_OptimizerLearningRate", func() error { return opt.Save(path.Join(dir, "optimizer.pt")) }},
+		{"Torch_OptimizerLearningRate", func() error { _, err := NewStepLR(opt, 1, 0.5); return err }},
+	}
+
+	for _, c := range cases {
+		setFailpoint(c.function)
+
+		var torchErr *Error
+		if err := c.call(); !errors.As(err, &torchErr) || !strings.Contains(torchErr.Message, c.function) {
+			t.Errorf("%s: expected a torch error but got %v", c.function, err)
+		}
+		if err := c.call(); err != nil {
+			t.Errorf("%s: failpoint should only fail once but got %v", c.function, err)
+		}
+	}
+
+	// Accessors without an error result panic instead of crashing the process
+	panics := []struct {
+		function string
+		call     func()
+	}{
+		{"Torch_JITModuleGetMethodNames", func() { module.GetMethodNames() }},
+		{"Torch_JITModuleMethodArguments", func() { method.Arguments() }},
+		{"Torch_JITModuleMethodReturns", func() { method.Returns() }},
+		{"Torch_TensorGrad", func() { w.Grad() }},
+		{"Torch_TensorRequiresGrad", func() { w.RequiresGrad() }},
+		{"Torch_NNModuleNamedParameters", func() { linear.Parameters() }},
+		{"Torch_NNModuleNamedParameters", func() { linear.NamedParameters() }},
+		{"Torch_NNModuleTrain", func() { linear.Train(false) }},
+		{"Torch_OptimizerLearningRate", func() { opt.LearningRate() }},
+	}
+
+	for _, c := range panics {
+		setFailpoint(c.function)
+
+		func() {
+			defer func() {
+				if _, ok := recover().(*Error); !ok {
+					t.Errorf("%s: expected a panic with a torch error", c.function)
+				}
+			}()
+			c.call()
+		}()
+	}
+}
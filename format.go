@@ -0,0 +1,362 @@
+package torch
+
+// #include "torch.hpp"
+import "C"
+import (
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// PrintOptions control how tensors are formatted as strings (as torch.set_printoptions in PyTorch)
+type PrintOptions struct {
+	// Precision is the number of digits after the decimal point of floating point elements
+	Precision int
+	// Threshold is the number of elements above which tensors are summarized
+	Threshold int
+	// EdgeItems is the number of items shown at the beginning and end of each dimension of summarized tensors
+	EdgeItems int
+}
+
+// DefaultPrintOptions are the print options used unless changed with SetPrintOptions
+var DefaultPrintOptions = PrintOptions{
+	Precision: 4,
+	Threshold: 1000,
+	EdgeItems: 3,
+}
+
+var (
+	printOptionsMutex sync.RWMutex
+	printOptions      = DefaultPrintOptions
+)
+
+// SetPrintOptions sets the print options used to format tensors
+func SetPrintOptions(opts PrintOptions) {
+	printOptionsMutex.Lock()
+	defer printOptionsMutex.Unlock()
+
+	printOptions = opts
+}
+
+// GetPrintOptions returns the print options used to format tensors
+func GetPrintOptions() PrintOptions {
+	printOptionsMutex.RLock()
+	defer printOptionsMutex.RUnlock()
+
+	return printOptions
+}
+
+// String formats the tensor as PyTorch prints tensors, e.g. tensor([1., 2.])
+func (t *Tensor) String() string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.format(GetPrintOptions(), false)
+}
+
+// Format implements fmt.Formatter. %v and %s format the tensor as String does, %+v adds the
+// data type, shape and strides and a precision (e.g. %.2v) overrides the print options.
+func (t *Tensor) Format(f fmt.State, verb rune) {
+	if verb != 'v' && verb != 's' {
+		fmt.Fprintf(f, "%%!%c(*torch.Tensor)", verb)
+		return
+	}
+	if t == nil {
+		io.WriteString(f, "<nil>")
+		return
+	}
+
+	opts := GetPrintOptions()
+	if precision, ok := f.Precision(); ok {
+		opts.Precision = precision
+	}
+
+	io.WriteString(f, t.format(opts, verb == 'v' && f.Flag('+')))
+}
+
+func (t *Tensor) format(opts PrintOptions, verbose bool) string {
+	dt, shape, elems, err := t.formatElements(opts)
+	if err != nil {
+		return "tensor(<error: " + err.Error() + ">)"
+	}
+
+	var strides []int64
+	if verbose {
+		// Strides are only informative, so a failure to read them is not fatal
+		strides, _ = t.StridesE()
+	}
+
+	return formatTensor(dt, shape, strides, elems, opts, verbose)
+}
+
+// formatTensor formats elements in C order of a tensor with given shape
+func formatTensor(dt DType, shape, strides []int64, elems formatElements, opts PrintOptions, verbose bool) string {
+	const prefix = "tensor("
+
+	var b strings.Builder
+	b.WriteString(prefix)
+
+	numel := numElements(shape)
+
+	// Shown elements are formatted together so that they share notation and width
+	shown, summarize := shownElements(shape, opts)
+	formatted := elems.format(shown, opts.Precision)
+
+	width := 0
+	for _, s := range formatted {
+		if len(s) > width {
+			width = len(s)
+		}
+	}
+
+	next := 0
+	var write func(dim int)
+	write = func(dim int) {
+		if dim == len(shape) {
+			s := formatted[next]
+			next++
+			b.WriteString(strings.Repeat(" ", width-len(s)) + s)
+			return
+		}
+
+		separator := ", "
+		if dim < len(shape)-1 {
+			separator = "," + strings.Repeat("\n", len(shape)-dim-1) + strings.Repeat(" ", len(prefix)+dim+1)
+		}
+
+		b.WriteString("[")
+		for i, index := range shownIndices(shape[dim], summarize, opts.EdgeItems) {
+			if i > 0 {
+				b.WriteString(separator)
+			}
+			if index < 0 {
+				b.WriteString("...")
+				continue
+			}
+			write(dim + 1)
+		}
+		b.WriteString("]")
+	}
+
+	if numel == 0 {
+		b.WriteString("[]")
+	} else {
+		write(0)
+	}
+
+	if verbose {
+		fmt.Fprintf(&b, ", dtype=torch.%s, shape=%s, strides=%s", dt, formatInts(shape), formatInts(strides))
+	} else if dt != Float && dt != Long {
+		b.WriteString(", dtype=torch." + dt.String())
+	}
+
+	b.WriteString(")")
+	return b.String()
+}
+
+// formatElements reads the elements of the tensor which are shown with given options
+func (t *Tensor) formatElements(opts PrintOptions) (DType, []int64, formatElements, error) {
+	dt, err := t.DTypeE()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if !supportedDType(dt) {
+		return 0, nil, nil, newError(UnsupportedTypeError, "unsupported DType %s", dt)
+	}
+
+	shape, err := t.ShapeE()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	shown, _ := shownElements(shape, opts)
+	size := int(typeOf(dt, nil).Size())
+	data := make([]byte, len(shown)*size)
+	if len(shown) > 0 {
+		var cErr C.Torch_Error
+		C.Torch_TensorGatherData(
+			t.context,
+			(*C.int64_t)(unsafe.Pointer(&shown[0])),
+			C.ulong(len(shown)),
+			unsafe.Pointer(&data[0]),
+			&cErr,
+		)
+		runtime.KeepAlive(t)
+		if err := checkError(cErr); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	var elems formatElements
+	switch dt {
+	case Float, Double:
+		values := make(floatElements, len(shown))
+		for i := range values {
+			if dt == Float {
+				values[i] = float64(math.Float32frombits(nativeEndian.Uint32(data[i*4:])))
+			} else {
+				values[i] = math.Float64frombits(nativeEndian.Uint64(data[i*8:]))
+			}
+		}
+		elems = values
+	default:
+		values := make(intElements, len(shown))
+		for i := range values {
+			switch dt {
+			case Byte:
+				values[i] = int64(data[i])
+			case Char:
+				values[i] = int64(int8(data[i]))
+			case Int:
+				values[i] = int64(int32(nativeEndian.Uint32(data[i*4:])))
+			case Long:
+				values[i] = int64(nativeEndian.Uint64(data[i*8:]))
+			}
+		}
+		elems = values
+	}
+
+	return dt, shape, shownOnly{shown, elems}, nil
+}
+
+// shownElements returns the flat indices (in C order) of the elements of a tensor with given shape
+// which are shown with given options and whether the tensor is summarized
+func shownElements(shape []int64, opts PrintOptions) ([]int64, bool) {
+	summarize := opts.Threshold >= 0 && numElements(shape) > int64(opts.Threshold)
+
+	var shown []int64
+	walkShown(shape, 0, 0, summarize, opts.EdgeItems, func(index int64) {
+		shown = append(shown, index)
+	})
+
+	return shown, summarize
+}
+
+// shownIndices returns the indices of a dimension of given size which are shown with -1 in place of
+// the summarized items
+func shownIndices(size int64, summarize bool, edgeItems int) []int64 {
+	edge := int64(edgeItems)
+	if summarize && size > 2*edge {
+		indices := make([]int64, 0, 2*edge+1)
+		for i := int64(0); i < edge; i++ {
+			indices = append(indices, i)
+		}
+		indices = append(indices, -1)
+		for i := size - edge; i < size; i++ {
+			indices = append(indices, i)
+		}
+		return indices
+	}
+
+	indices := make([]int64, size)
+	for i := range indices {
+		indices[i] = int64(i)
+	}
+	return indices
+}
+
+// walkShown calls fn with the flat index of every element shown in C order
+func walkShown(shape []int64, dim int, offset int64, summarize bool, edgeItems int, fn func(index int64)) {
+	if dim == len(shape) {
+		fn(offset)
+		return
+	}
+
+	stride := numElements(shape[dim+1:])
+	for _, index := range shownIndices(shape[dim], summarize, edgeItems) {
+		if index >= 0 {
+			walkShown(shape, dim+1, offset+index*stride, summarize, edgeItems, fn)
+		}
+	}
+}
+
+func formatInts(values []int64) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.FormatInt(v, 10)
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+type formatElements interface {
+	// format formats the elements at given indices
+	format(indices []int64, precision int) []string
+}
+
+// shownOnly holds only the shown elements of a tensor: elems[i] is the element at flat index indices[i]
+type shownOnly struct {
+	indices []int64
+	elems   formatElements
+}
+
+func (e shownOnly) format(indices []int64, precision int) []string {
+	positions := make([]int64, len(indices))
+	for i, index := range indices {
+		positions[i] = int64(sort.Search(len(e.indices), func(j int) bool { return e.indices[j] >= index }))
+	}
+	return e.elems.format(positions, precision)
+}
+
+type intElements []int64
+
+func (e intElements) format(indices []int64, precision int) []string {
+	formatted := make([]string, len(indices))
+	for i, index := range indices {
+		formatted[i] = strconv.FormatInt(e[index], 10)
+	}
+	return formatted
+}
+
+type floatElements []float64
+
+// format formats floats as PyTorch does: integral values as 1., others with fixed precision and
+// in scientific notation when the magnitudes of the values differ too much
+func (e floatElements) format(indices []int64, precision int) []string {
+	integral := true
+	maxAbs, minAbs := 0.0, math.Inf(1)
+	for _, index := range indices {
+		v := e[index]
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		if v != math.Trunc(v) {
+			integral = false
+		}
+		abs := math.Abs(v)
+		if abs != 0 {
+			maxAbs = math.Max(maxAbs, abs)
+			minAbs = math.Min(minAbs, abs)
+		}
+	}
+
+	sci := maxAbs > 0 && (maxAbs/minAbs > 1000 || maxAbs > 1e8)
+	if !integral && minAbs < 1e-4 {
+		sci = true
+	}
+
+	formatted := make([]string, len(indices))
+	for i, index := range indices {
+		v := e[index]
+		switch {
+		case math.IsNaN(v):
+			formatted[i] = "nan"
+		case math.IsInf(v, 1):
+			formatted[i] = "inf"
+		case math.IsInf(v, -1):
+			formatted[i] = "-inf"
+		case sci:
+			formatted[i] = strconv.FormatFloat(v, 'e', precision, 64)
+		case integral:
+			formatted[i] = strconv.FormatFloat(v, 'f', 0, 64) + "."
+		default:
+			formatted[i] = strconv.FormatFloat(v, 'f', precision, 64)
+		}
+	}
+	return formatted
+}
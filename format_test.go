@@ -0,0 +1,105 @@
+package torch
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_formatTensor(t *testing.T) {
+	opts := DefaultPrintOptions
+
+	cases := []struct {
+		dt       DType
+		shape    []int64
+		elems    formatElements
+		opts     PrintOptions
+		expected string
+	}{
+		{Float, []int64{}, floatElements{3}, opts, "tensor(3.)"},
+		{Float, []int64{0}, floatElements{}, opts, "tensor([])"},
+		{Float, []int64{2}, floatElements{1, 2}, opts, "tensor([1., 2.])"},
+		{Float, []int64{3}, floatElements{1.5, -2, 10}, opts, "tensor([ 1.5000, -2.0000, 10.0000])"},
+		{Float, []int64{2}, floatElements{1e-5, 1}, opts, "tensor([1.0000e-05, 1.0000e+00])"},
+		{Float, []int64{3}, floatElements{math.NaN(), math.Inf(1), 1}, opts, "tensor([nan, inf,  1.])"},
+		{Double, []int64{1}, floatElements{0.5}, opts, "tensor([0.5000], dtype=torch.float64)"},
+		{Float, []int64{1}, floatElements{1.23456}, PrintOptions{Precision: 2, Threshold: 1000, EdgeItems: 3}, "tensor([1.23])"},
+		{Long, []int64{3}, intElements{1, -20, 300}, opts, "tensor([  1, -20, 300])"},
+		{Byte, []int64{2}, intElements{1, 2}, opts, "tensor([1, 2], dtype=torch.uint8)"},
+		{
+			Float, []int64{2, 2}, floatElements{1, 2, 3, 4}, opts,
+			"tensor([[1., 2.],\n        [3., 4.]])",
+		},
+		{
+			Long, []int64{2, 1, 2}, intElements{1, 2, 3, 4}, opts,
+			"tensor([[[1, 2]],\n\n        [[3, 4]]])",
+		},
+		{
+			Long, []int64{8}, intElements{0, 1, 2, 3, 4, 5, 6, 7}, PrintOptions{Threshold: 4, EdgeItems: 2},
+			"tensor([0, 1, ..., 6, 7])",
+		},
+		{
+			Long, []int64{5, 2}, intElements{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, PrintOptions{Threshold: 4, EdgeItems: 1},
+			"tensor([[0, 1],\n        ...,\n        [8, 9]])",
+		},
+		{
+			Long, []int64{5, 2}, shownOnly{[]int64{0, 1, 8, 9}, intElements{0, 1, 8, 9}}, PrintOptions{Threshold: 4, EdgeItems: 1},
+			"tensor([[0, 1],\n        ...,\n        [8, 9]])",
+		},
+	}
+
+	for _, c := range cases {
+		formatted := formatTensor(c.dt, c.shape, nil, c.elems, c.opts, false)
+		if formatted != c.expected {
+			t.Errorf("expected\n%s\nbut got\n%s", c.expected, formatted)
+		}
+	}
+
+	verbose := formatTensor(Float, []int64{2, 1}, []int64{1, 1}, floatElements{1, 2}, opts, true)
+	if expected := "tensor([[1.],\n        [2.]], dtype=torch.float32, shape=[2, 1], strides=[1, 1])"; verbose != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, verbose)
+	}
+}
+
+func Test_TensorFormat(t *testing.T) {
+	a, _ := NewTensor([][]float32{{1, 2.5}, {3, 4}})
+
+	if s := a.String(); s != "tensor([[1.0000, 2.5000],\n        [3.0000, 4.0000]])" {
+		t.Error("wrong string", s)
+	}
+	if s := fmt.Sprintf("%.1v", a); s != "tensor([[1.0, 2.5],\n        [3.0, 4.0]])" {
+		t.Error("wrong string", s)
+	}
+	if s := fmt.Sprintf("%+v", a); !strings.HasSuffix(s, ", dtype=torch.float32, shape=[2, 2], strides=[2, 1])") {
+		t.Error("wrong verbose string", s)
+	}
+	if s := fmt.Sprintf("%d", a); s != "%!d(*torch.Tensor)" {
+		t.Error("wrong string for unsupported verb", s)
+	}
+
+	var nilTensor *Tensor
+	if s := fmt.Sprint(nilTensor); s != "<nil>" {
+		t.Error("wrong string for nil tensor", s)
+	}
+
+	large, _ := NewTensor(make([]int32, 2000))
+	if s := large.String(); s != "tensor([0, 0, 0, ..., 0, 0, 0], dtype=torch.int32)" {
+		t.Error("large tensors should be summarized", s)
+	}
+
+	module, err := CompileTorchScript(`
+def transpose(a):
+	return a.t()
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := module.RunMethod("transpose", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.(*Tensor).String(); s != "tensor([[1.0000, 3.0000],\n        [2.5000, 4.0000]])" {
+		t.Error("non-contiguous tensors should be printed in C order", s)
+	}
+}
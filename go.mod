@@ -0,0 +1,3 @@
+module github.com/orktes/go-torch
+
+go 1.14
@@ -8,14 +8,28 @@ package torch
 //
 import "C"
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"sort"
+	"sync"
 	"unsafe"
 )
 
 // JITModule is a jit compiled PyTorch module
+//
+// A JITModule (and its JITModuleMethods) can be shared by goroutines: Run, RunMethod, Forward,
+// GetMethod, GetMethodNames, Arguments, Returns and StateDict may be called concurrently. Calls that
+// mutate the module (SetParameter, LoadStateDict, LoadSafetensors) must not run concurrently with any
+// other call on the same module. Modules whose methods mutate their own state (attributes, buffers
+// such as running statistics) must not be run concurrently either; use a ModulePool of clones instead.
 type JITModule struct {
 	context C.Torch_JITModuleContext
+
+	// methods caches method lookups done by GetMethod. Contexts are held in methodContexts which do
+	// not refer back to the module so that finalizers are not part of a reference cycle.
+	methodsMutex sync.RWMutex
+	methods      map[string]*methodContext
 }
 
 // CompileTorchScript compiles TorchScript and returns a *JITModule
@@ -26,7 +40,7 @@ func CompileTorchScript(torchScript string) (*JITModule, error) {
 	var cErr C.Torch_Error
 	ctx := C.Torch_CompileTorchScript(cstr, &cErr)
 	if err := checkError(cErr); err != nil {
-		return nil, err
+		return nil, compileError(err, torchScript)
 	}
 
 	mod := &JITModule{context: ctx}
@@ -67,8 +81,51 @@ func (m *JITModule) Save(path string) error {
 	return nil
 }
 
-// GetMethod returns a method from a JITModule
+// Clone returns a deep copy of the module (code, parameters and buffers)
+func (m *JITModule) Clone() (*JITModule, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_JITModuleClone(m.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	mod := &JITModule{context: ctx}
+	runtime.SetFinalizer(mod, (*JITModule).finalize)
+
+	return mod, nil
+}
+
+// GetMethod returns a method from a JITModule. Method lookups are cached on the module (until it
+// is mutated) so that repeated calls are cheap.
 func (m *JITModule) GetMethod(method string) (*JITModuleMethod, error) {
+	m.methodsMutex.RLock()
+	ctx, ok := m.methods[method]
+	m.methodsMutex.RUnlock()
+
+	if !ok {
+		var err error
+		ctx, err = m.lookupMethod(method)
+		if err != nil {
+			return nil, err
+		}
+
+		m.methodsMutex.Lock()
+		if cached, ok := m.methods[method]; ok {
+			ctx = cached
+		} else {
+			if m.methods == nil {
+				m.methods = map[string]*methodContext{}
+			}
+			m.methods[method] = ctx
+		}
+		m.methodsMutex.Unlock()
+	}
+
+	return &JITModuleMethod{context: ctx.context, methodContext: ctx, Module: m, Name: method}, nil
+}
+
+// lookupMethod looks up a method without using the cache
+func (m *JITModule) lookupMethod(method string) (*methodContext, error) {
 	cstr := C.CString(method)
 	defer C.free(unsafe.Pointer(cstr))
 
@@ -78,11 +135,18 @@ func (m *JITModule) GetMethod(method string) (*JITModuleMethod, error) {
 		return nil, err
 	}
 
-	met := &JITModuleMethod{context: context, Module: m, Name: method}
+	ctx := &methodContext{context: context}
+	runtime.SetFinalizer(ctx, (*methodContext).finalize)
+
+	return ctx, nil
+}
 
-	runtime.SetFinalizer(met, (*JITModuleMethod).finalize)
+// invalidateMethods clears the method cache after the module has been mutated
+func (m *JITModule) invalidateMethods() {
+	m.methodsMutex.Lock()
+	defer m.methodsMutex.Unlock()
 
-	return met, nil
+	m.methods = nil
 }
 
 // RunMethod executes given method with tensors or tuples as input
@@ -100,10 +164,44 @@ func (m *JITModule) Forward(inputs ...interface{}) (interface{}, error) {
 	return m.RunMethod("forward", inputs...)
 }
 
-// GetMethodNames returns all method names from the module
+// RunMethodContext executes given method like RunMethod respecting ctx (see JITModuleMethod.RunContext)
+func (m *JITModule) RunMethodContext(ctx context.Context, method string, inputs ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	met, err := m.GetMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	return met.RunContext(ctx, inputs...)
+}
+
+// ForwardContext executes forward method of the module respecting ctx (see JITModuleMethod.RunContext)
+func (m *JITModule) ForwardContext(ctx context.Context, inputs ...interface{}) (interface{}, error) {
+	return m.RunMethodContext(ctx, "forward", inputs...)
+}
+
+// GetMethodNames returns all method names from the module. It panics if LibTorch fails (see GetMethodNamesE).
 func (m *JITModule) GetMethodNames() []string {
+	names, err := m.GetMethodNamesE()
+	if err != nil {
+		panic(err)
+	}
+	return names
+}
+
+// GetMethodNamesE returns all method names from the module
+func (m *JITModule) GetMethodNamesE() ([]string, error) {
 	var resLen C.ulong
-	cnamesPtr := C.Torch_JITModuleGetMethodNames(m.context, &resLen)
+	var cErr C.Torch_Error
+	cnamesPtr := C.Torch_JITModuleGetMethodNames(m.context, &resLen, &cErr)
+	runtime.KeepAlive(m)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
 	resSlice := (*[1 << 30]*C.char)(unsafe.Pointer(cnamesPtr))[:resLen:resLen]
 	defer C.free(unsafe.Pointer(cnamesPtr))
 
@@ -114,7 +212,26 @@ func (m *JITModule) GetMethodNames() []string {
 		C.free(unsafe.Pointer(name))
 	}
 
-	return names
+	return names, nil
+}
+
+// SetParameter copies value into the parameter (or buffer) with given name. Parameters of
+// submodules are referred to with dotted names (e.g. "layer1.weight").
+func (m *JITModule) SetParameter(name string, value *Tensor) error {
+	cstr := C.CString(name)
+	defer C.free(unsafe.Pointer(cstr))
+
+	var cErr C.Torch_Error
+	C.Torch_JITModuleSetParameter(m.context, cstr, value.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	runtime.KeepAlive(value)
+
+	m.invalidateMethods()
+
+	return nil
 }
 
 func (m *JITModule) finalize() {
@@ -123,12 +240,23 @@ func (m *JITModule) finalize() {
 
 // JITModuleMethod is single method from a JITModule
 type JITModuleMethod struct {
+	context       C.Torch_JITModuleMethodContext
+	methodContext *methodContext
+	Module        *JITModule
+	Name          string
+}
+
+// methodContext owns a method context (possibly shared by many JITModuleMethods)
+type methodContext struct {
 	context C.Torch_JITModuleMethodContext
-	Module  *JITModule
-	Name    string
 }
 
-// Run executes given method with tensors as input
+func (c *methodContext) finalize() {
+	C.Torch_DeleteJITModuleMethod(c.context)
+}
+
+// Run executes given method with inputs. Inputs can be tensors, tuples, int, int64, float64, bool
+// or nil (None). Scalar and None outputs are returned as int64, float64, bool and nil.
 func (m *JITModuleMethod) Run(inputs ...interface{}) (interface{}, error) {
 	ivalues := make([]C.Torch_IValue, len(inputs))
 	for i, t := range inputs {
@@ -161,60 +289,245 @@ func (m *JITModuleMethod) Run(inputs ...interface{}) (interface{}, error) {
 	defer freeIValues([]C.Torch_IValue{ival})
 
 	runtime.KeepAlive(inputs)
+	runtime.KeepAlive(m)
+
+	return convertIValueToGoType(ival)
+}
+
+// maxAbandonedRuns bounds the number of computations RunContext leaves running in the background
+const maxAbandonedRuns = 64
+
+// abandonedRuns holds a slot for every computation left running by RunContext
+var abandonedRuns = make(chan struct{}, maxAbandonedRuns)
+
+// RunContext executes given method like Run but returns ctx.Err() (context.Canceled or
+// context.DeadlineExceeded, never a *Error) if ctx is done before the method is dispatched or
+// before it returns. LibTorch 1.0 has no mechanism for interrupting a running method: if ctx is
+// done while the method is running, RunContext returns right away and the computation finishes in
+// the background with its result discarded. At most 64 computations are left running this way;
+// once that bound is reached RunContext waits for the computation to finish before returning
+// ctx.Err(). Long autoregressive loops should therefore be driven from Go, calling RunContext
+// once per step (or chaining steps with Sequential.ForwardContext) so that cancellation is
+// checked between steps.
+func (m *JITModuleMethod) RunContext(ctx context.Context, inputs ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if ctx.Done() == nil {
+		// Context can never be cancelled
+		return m.Run(inputs...)
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := m.Run(inputs...)
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+	}
+
+	select {
+	case abandonedRuns <- struct{}{}:
+		go func() {
+			<-done
+			<-abandonedRuns
+		}()
+	default:
+		// Too many computations running in the background already
+		<-done
+	}
+
+	return nil, ctx.Err()
+}
+
+// RunWithKwargs executes given method with positional args followed by keyword arguments.
+// Arguments which are given neither positionally nor as keywords are filled with the default
+// values of the method schema. Arguments which do not bind to the schema are reported as *ArgumentError.
+func (m *JITModuleMethod) RunWithKwargs(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if _, err := m.bindArguments(args, kwargs); err != nil {
+		return nil, err
+	}
+
+	ivalues := make([]C.Torch_IValue, len(args))
+	defer freeIValues(ivalues)
+	for i, arg := range args {
+		var err error
+		ivalues[i], err = convertGoValueToIValue(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	kwValues := make([]C.Torch_IValue, len(names))
+	defer freeIValues(kwValues)
+	for i, name := range names {
+		var err error
+		kwValues[i], err = convertGoValueToIValue(kwargs[name])
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", name, err)
+		}
+	}
+
+	cNames := cStrings(names)
+	defer freeCStrings(cNames)
+
+	var iValuePtr, kwValuePtr *C.Torch_IValue
+	if len(ivalues) > 0 {
+		iValuePtr = &ivalues[0]
+	}
+	if len(kwValues) > 0 {
+		kwValuePtr = &kwValues[0]
+	}
+
+	var cErr C.Torch_Error
+	ival := C.Torch_JITModuleMethodRunKwargs(
+		m.context,
+		iValuePtr,
+		C.ulong(len(ivalues)),
+		cStringsPtr(cNames),
+		kwValuePtr,
+		C.ulong(len(kwValues)),
+		&cErr,
+	)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	defer freeIValues([]C.Torch_IValue{ival})
+
+	runtime.KeepAlive(args)
+	runtime.KeepAlive(kwargs)
+	runtime.KeepAlive(m)
 
 	return convertIValueToGoType(ival)
 }
 
-// Arguments returns method arguments for the method schema
+// Arguments returns method arguments for the method schema. It panics if LibTorch fails (see ArgumentsE).
 func (m *JITModuleMethod) Arguments() []JITModuleMethodArgument {
+	args, err := m.ArgumentsE()
+	if err != nil {
+		panic(err)
+	}
+	return args
+}
+
+// ArgumentsE returns method arguments for the method schema
+func (m *JITModuleMethod) ArgumentsE() ([]JITModuleMethodArgument, error) {
 	var resSize C.ulong
-	resPtr := C.Torch_JITModuleMethodArguments(m.context, &resSize)
+	var cErr C.Torch_Error
+	resPtr := C.Torch_JITModuleMethodArguments(m.context, &resSize, &cErr)
+	runtime.KeepAlive(m)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
 	defer C.free(unsafe.Pointer(resPtr))
 
 	resSlice := (*[1 << 30]C.Torch_ModuleMethodArgument)(unsafe.Pointer(resPtr))[:resSize:resSize]
 
 	args := make([]JITModuleMethodArgument, int(resSize))
 	for i, arg := range resSlice {
-		args[i] = JITModuleMethodArgument{
-			Name: C.GoString(arg.name),
-			Type: C.GoString(arg.typ),
+		args[i] = newJITModuleMethodArgument(C.GoString(arg.name), C.GoString(arg.typ))
+		args[i].HasDefault = arg.has_default != 0
+		args[i].KwargOnly = arg.kwarg_only != 0
+		if args[i].HasDefault {
+			// Defaults of types without a Go representation (e.g. lists) are returned as nil
+			args[i].Default, _ = convertIValueToGoType(arg.default_value)
+			freeIValues([]C.Torch_IValue{arg.default_value})
 		}
 		C.free(unsafe.Pointer(arg.typ))
 		C.free(unsafe.Pointer(arg.name))
 	}
 
-	return args
+	return args, nil
 }
 
-// Returns returns method return type information for the method schema
+// Returns returns method return type information for the method schema. It panics if LibTorch fails (see ReturnsE).
 func (m *JITModuleMethod) Returns() []JITModuleMethodArgument {
+	returns, err := m.ReturnsE()
+	if err != nil {
+		panic(err)
+	}
+	return returns
+}
+
+// ReturnsE returns method return type information for the method schema
+func (m *JITModuleMethod) ReturnsE() ([]JITModuleMethodArgument, error) {
 	var resSize C.ulong
-	resPtr := C.Torch_JITModuleMethodReturns(m.context, &resSize)
+	var cErr C.Torch_Error
+	resPtr := C.Torch_JITModuleMethodReturns(m.context, &resSize, &cErr)
+	runtime.KeepAlive(m)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
 	defer C.free(unsafe.Pointer(resPtr))
 
 	resSlice := (*[1 << 30]C.Torch_ModuleMethodArgument)(unsafe.Pointer(resPtr))[:resSize:resSize]
 
 	args := make([]JITModuleMethodArgument, int(resSize))
 	for i, arg := range resSlice {
-		args[i] = JITModuleMethodArgument{
-			Name: C.GoString(arg.name),
-			Type: C.GoString(arg.typ),
-		}
+		args[i] = newJITModuleMethodArgument(C.GoString(arg.name), C.GoString(arg.typ))
 		C.free(unsafe.Pointer(arg.typ))
 		C.free(unsafe.Pointer(arg.name))
 	}
 
-	return args
+	return args, nil
 }
 
-func (m *JITModuleMethod) finalize() {
-	C.Torch_DeleteJITModuleMethod(m.context)
+// Schema returns the full method schema, e.g. forward(Tensor x, int n=2) -> Tensor
+func (m *JITModuleMethod) Schema() (string, error) {
+	var cErr C.Torch_Error
+	cSchema := C.Torch_JITModuleMethodSchema(m.context, &cErr)
+	runtime.KeepAlive(m)
+	if err := checkError(cErr); err != nil {
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(cSchema))
+
+	return C.GoString(cSchema), nil
 }
 
 // JITModuleMethodArgument contains information of a single method argument
 type JITModuleMethodArgument struct {
 	Name string
+	// Type is the type as printed by LibTorch (e.g. int[] or Tensor?)
 	Type string
+	// TypeInfo is the structured representation of Type
+	TypeInfo Type
+	// HasDefault is true if the argument has a default value
+	HasDefault bool
+	// Default is the default value of the argument (nil for None or if HasDefault is false)
+	Default interface{}
+	// KwargOnly is true if the argument can only be given as a keyword argument
+	KwargOnly bool
+}
+
+func newJITModuleMethodArgument(name, typ string) JITModuleMethodArgument {
+	typeInfo, err := ParseType(typ)
+	if err != nil {
+		typeInfo = OtherType{Name: typ}
+	}
+
+	return JITModuleMethodArgument{
+		Name:     name,
+		Type:     typ,
+		TypeInfo: typeInfo,
+	}
 }
 
 func freeTuple(tuple *C.Torch_IValueTuple) {
@@ -228,6 +541,8 @@ func freeIValues(values []C.Torch_IValue) {
 	for _, val := range values {
 		if val.itype == C.Torch_IValueTypeTuple {
 			freeTuple((*C.Torch_IValueTuple)(val.data_ptr))
+		} else if val.itype == C.Torch_IValueTypeUnsupported {
+			C.free(val.data_ptr)
 		}
 	}
 }
@@ -239,10 +554,19 @@ func convertIValueToGoType(ival C.Torch_IValue) (interface{}, error) {
 	} else if ival.itype == C.Torch_IValueTypeTuple {
 		tuple := (*C.Torch_IValueTuple)(ival.data_ptr)
 		return convertIValueTupleToTuple(tuple)
+	} else if ival.itype == C.Torch_IValueTypeInt {
+		return int64(ival.int_value), nil
+	} else if ival.itype == C.Torch_IValueTypeDouble {
+		return float64(ival.double_value), nil
+	} else if ival.itype == C.Torch_IValueTypeBool {
+		return ival.int_value != 0, nil
+	} else if ival.itype == C.Torch_IValueTypeNone {
+		return nil, nil
+	} else if ival.itype == C.Torch_IValueTypeUnsupported {
+		return nil, newError(UnsupportedTypeError, "unsupported value of type %s", C.GoString((*C.char)(ival.data_ptr)))
 	}
 
-	// TODO handle errors
-	return nil, nil
+	return nil, newError(UnsupportedTypeError, "unsupported value of type %d", int(ival.itype))
 }
 
 func convertIValueTupleToTuple(tuple *C.Torch_IValueTuple) (Tuple, error) {
@@ -250,13 +574,18 @@ func convertIValueTupleToTuple(tuple *C.Torch_IValueTuple) (Tuple, error) {
 
 	goTuple := make(Tuple, len(valuesSlice))
 
+	// All values are converted even if one fails so that tensors are not leaked
+	var firstErr error
 	for i, ival := range valuesSlice {
 		var err error
 		goTuple[i], err = convertIValueToGoType(ival)
-		if err != nil {
-			return nil, err
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
 
 	return goTuple, nil
 }
@@ -287,7 +616,17 @@ func convertGoValueToIValue(val interface{}) (C.Torch_IValue, error) {
 			itype:    C.Torch_IValueTypeTuple,
 			data_ptr: unsafe.Pointer(tuple),
 		}, nil
+	case int:
+		return C.Torch_IValue{itype: C.Torch_IValueTypeInt, int_value: C.int64_t(v)}, nil
+	case int64:
+		return C.Torch_IValue{itype: C.Torch_IValueTypeInt, int_value: C.int64_t(v)}, nil
+	case float64:
+		return C.Torch_IValue{itype: C.Torch_IValueTypeDouble, double_value: C.double(v)}, nil
+	case bool:
+		return C.Torch_IValue{itype: C.Torch_IValueTypeBool, int_value: C.int64_t(cBool(v))}, nil
+	case nil:
+		return C.Torch_IValue{itype: C.Torch_IValueTypeNone}, nil
 	default:
-		return C.Torch_IValue{}, fmt.Errorf("invalid input type for run %T", val)
+		return C.Torch_IValue{}, newError(UnsupportedTypeError, "invalid input type for run %T", val)
 	}
 }
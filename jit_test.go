@@ -1,11 +1,17 @@
 package torch
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const sumScript = `
@@ -24,6 +30,73 @@ def sum_sub(a, b):
 	return (a + b, a - b)
 `
 
+// newParameterModule returns a module with parameters fc.weight ([[1, 2], [3, 4]]), fc.bias
+// ([5, 6]) and scale ([7]).
+func newParameterModule(t *testing.T) *JITModule {
+	dir, err := ioutil.TempDir("", "modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	weight, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	bias, _ := NewTensor([]float32{5, 6})
+	scale, _ := NewTensor([]float32{7})
+
+	file := path.Join(dir, "module.pt")
+	err = saveModuleArchive(file, map[string]*Tensor{
+		"fc.weight": weight,
+		"fc.bias":   bias,
+		"scale":     scale,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	module, err := LoadJITModule(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return module
+}
+
+func Test_SetParameter(t *testing.T) {
+	module := newParameterModule(t)
+
+	weight, _ := NewTensor([][]float32{{-1, -2}, {-3, -4}})
+	if err := module.SetParameter("fc.weight", weight); err != nil {
+		t.Fatal(err)
+	}
+	scale, _ := NewTensor([]float32{8})
+	if err := module.SetParameter("scale", scale); err != nil {
+		t.Fatal(err)
+	}
+
+	stateDict, err := module.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val := stateDict["fc.weight"].Value(); !reflect.DeepEqual(val, [][]float32{{-1, -2}, {-3, -4}}) {
+		t.Error("wrong weight after SetParameter", val)
+	}
+	if val := stateDict["fc.bias"].Value(); !reflect.DeepEqual(val, []float32{5, 6}) {
+		t.Error("bias should not change", val)
+	}
+	if val := stateDict["scale"].Value(); !reflect.DeepEqual(val, []float32{8}) {
+		t.Error("wrong scale after SetParameter", val)
+	}
+
+	if err := module.SetParameter("fc.missing", scale); err == nil {
+		t.Error("should return an error for unknown parameters")
+	}
+	if err := module.SetParameter("fc.bias", scale); err == nil {
+		t.Error("should return an error for mismatching sizes")
+	}
+}
+
 func Test_CompileTorchScript(t *testing.T) {
 	module, err := CompileTorchScript(sumScript)
 	if err != nil {
@@ -221,3 +294,532 @@ func Test_SaveAndLoadJITModule(t *testing.T) {
 		t.Error("2 + 2 should equal 4 but got", res.(*Tensor).Value())
 	}
 }
+
+func Test_StateDict(t *testing.T) {
+	module, err := CompileTorchScript(sumScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateDict, err := module.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stateDict) != 0 {
+		t.Error("compiled functions should not have parameters", stateDict)
+	}
+
+	weight, _ := NewTensor([]float32{1})
+
+	keys, err := module.LoadStateDict(map[string]*Tensor{"fc.weight": weight}, false)
+	if err != nil {
+		t.Error("unexpected keys should be ignored in non-strict mode", err)
+	}
+	if !reflect.DeepEqual(keys.Unexpected, []string{"fc.weight"}) {
+		t.Error("unexpected keys should be returned", keys)
+	}
+
+	_, err = module.LoadStateDict(map[string]*Tensor{"fc.weight": weight}, true)
+	if err == nil {
+		t.Fatal("should return an error")
+	}
+
+	if err.Error() != "error loading state dict: unexpected keys: fc.weight" {
+		t.Error("wrong message returned", err)
+	}
+}
+
+func Test_LoadStateDict(t *testing.T) {
+	module := newParameterModule(t)
+
+	weight, _ := NewTensor([][]float32{{-1, -2}, {-3, -4}})
+	bias, _ := NewTensor([]float32{-5, -6})
+	extra, _ := NewTensor([]float32{0})
+
+	keys, err := module.LoadStateDict(map[string]*Tensor{"fc.weight": weight, "fc.bias": bias, "extra": extra}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, IncompatibleKeys{Missing: []string{"scale"}, Unexpected: []string{"extra"}}) {
+		t.Error("wrong incompatible keys returned", keys)
+	}
+
+	stateDict, err := module.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := stateDict["fc.weight"].Value(); !reflect.DeepEqual(val, [][]float32{{-1, -2}, {-3, -4}}) {
+		t.Error("wrong weight loaded", val)
+	}
+	if val := stateDict["fc.bias"].Value(); !reflect.DeepEqual(val, []float32{-5, -6}) {
+		t.Error("wrong bias loaded", val)
+	}
+	if val := stateDict["scale"].Value(); !reflect.DeepEqual(val, []float32{7}) {
+		t.Error("missing parameters should not change", val)
+	}
+
+	// Nothing is copied in strict mode unless all keys match
+	_, err = module.LoadStateDict(map[string]*Tensor{"fc.weight": extra}, true)
+	if dictErr, ok := err.(*StateDictError); !ok || len(dictErr.Missing) != 2 || len(dictErr.Mismatched) != 1 {
+		t.Fatal("wrong error returned", err)
+	}
+
+	stateDict, _ = module.StateDict()
+	if val := stateDict["fc.weight"].Value(); !reflect.DeepEqual(val, [][]float32{{-1, -2}, {-3, -4}}) {
+		t.Error("weight should not change in strict mode", val)
+	}
+}
+
+func Test_RunContext(t *testing.T) {
+	module, err := CompileTorchScript(sumScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := NewTensor([]float32{1, 2})
+	b, _ := NewTensor([]float32{3, 4})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	res, err := module.RunMethodContext(ctx, "sum", a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.(*Tensor).Value(), []float32{4, 6}) {
+		t.Error("wrong result", res.(*Tensor).Value())
+	}
+
+	cancel()
+
+	if _, err := module.RunMethodContext(ctx, "sum", a, b); err != context.Canceled {
+		t.Error("expected context.Canceled", err)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	method, _ := module.GetMethod("sum")
+	_, err = method.RunContext(ctx, a, b)
+	if err != context.DeadlineExceeded {
+		t.Error("expected context.DeadlineExceeded", err)
+	}
+	if _, ok := err.(*Error); ok {
+		t.Error("deadline errors should not be torch errors")
+	}
+}
+
+func Test_NumThreads(t *testing.T) {
+	defer SetNumThreads(GetNumThreads())
+
+	if err := SetNumThreads(2); err != nil {
+		t.Fatal(err)
+	}
+	if GetNumThreads() != 2 {
+		t.Error("wrong number of threads", GetNumThreads())
+	}
+
+	if err := SetNumThreads(0); err == nil {
+		t.Error("should return an error for zero threads")
+	}
+}
+
+const matmulScript = `
+def forward(a, b):
+	return torch.relu(torch.mm(a, b))
+`
+
+// Benchmark_ConcurrentRun measures throughput of concurrent Run calls (GOMAXPROCS goroutines) with
+// the default intra-op thread pool and with a single intra-op thread per call
+func Benchmark_ConcurrentRun(b *testing.B) {
+	module, err := CompileTorchScript(matmulScript)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data := make([]float32, 128*128)
+	for i := range data {
+		data[i] = float32(i%7) / 7
+	}
+	input, err := NewTensorWithShape(data, []int64{128, 128}, Float)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defaultThreads := GetNumThreads()
+	defer SetNumThreads(defaultThreads)
+
+	for _, threads := range []int{defaultThreads, 1} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			if err := SetNumThreads(threads); err != nil {
+				b.Fatal(err)
+			}
+
+			b.RunParallel(func(pb *testing.PB) {
+				method, err := module.GetMethod("forward")
+				if err != nil {
+					b.Error(err)
+					return
+				}
+
+				for pb.Next() {
+					if _, err := method.Run(input, input); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func Test_Clone(t *testing.T) {
+	module, err := CompileTorchScript(sumScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := module.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(clone.GetMethodNames(), module.GetMethodNames()) {
+		t.Error("clone has different methods", clone.GetMethodNames())
+	}
+
+	a, _ := NewTensor([]float32{1, 2})
+	res, err := clone.RunMethod("sum", a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.(*Tensor).Value(), []float32{2, 4}) {
+		t.Error("wrong result", res.(*Tensor).Value())
+	}
+}
+
+func Test_CloneParameters(t *testing.T) {
+	module := newParameterModule(t)
+
+	clone, err := module.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateDict, err := clone.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := stateDict["fc.weight"].Value(); !reflect.DeepEqual(val, [][]float32{{1, 2}, {3, 4}}) {
+		t.Error("clone should have the parameters of the module", val)
+	}
+
+	// Parameters are updated in place so a shallow clone would change the module too
+	weight, _ := NewTensor([][]float32{{-1, -2}, {-3, -4}})
+	if err := clone.SetParameter("fc.weight", weight); err != nil {
+		t.Fatal(err)
+	}
+
+	stateDict, err = module.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := stateDict["fc.weight"].Value(); !reflect.DeepEqual(val, [][]float32{{1, 2}, {3, 4}}) {
+		t.Error("changing the clone should not change the module", val)
+	}
+}
+
+// Test_ConcurrentRun runs a shared module and method from many goroutines (run with -race)
+func Test_ConcurrentRun(t *testing.T) {
+	module, err := CompileTorchScript(sumScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := module.GetMethod("sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			a, _ := NewTensor([]float32{float32(i)})
+			for j := 0; j < 10; j++ {
+				res, err := method.Run(a, a)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if res.(*Tensor).Value().([]float32)[0] != float32(i)*2 {
+					t.Error("wrong result", res.(*Tensor).Value())
+				}
+
+				if _, err := module.Forward(a, a); err == nil {
+					t.Error("module has no forward method")
+				}
+				module.GetMethodNames()
+				method.Arguments()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_GetMethodCache(t *testing.T) {
+	module, err := CompileTorchScript(sumScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := module.GetMethod("sum")
+	b, _ := module.GetMethod("sum")
+	if a.methodContext != b.methodContext {
+		t.Error("method lookups should be cached")
+	}
+
+	module.invalidateMethods()
+
+	c, _ := module.GetMethod("sum")
+	if c.methodContext == a.methodContext {
+		t.Error("cache should be invalidated")
+	}
+
+	// Methods looked up before invalidation stay usable
+	x, _ := NewTensor([]float32{1})
+	if _, err := a.Run(x, x); err != nil {
+		t.Error(err)
+	}
+}
+
+func Benchmark_Forward(b *testing.B) {
+	module, err := CompileTorchScript(`
+def forward(a):
+	return a
+`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	input, _ := NewTensor([]float32{1})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			module.Forward(input)
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ctx, _ := module.lookupMethod("forward")
+			method := &JITModuleMethod{context: ctx.context, methodContext: ctx, Module: module, Name: "forward"}
+			method.Run(input)
+		}
+	})
+}
+
+const defaultsScript = `
+def generate(input, max_len: int = 20, temperature: float = 1.0, double: bool = False):
+	res = input * temperature + max_len
+	if double:
+		res = res * 2
+	return res
+`
+
+func Test_RunWithKwargs(t *testing.T) {
+	module, err := CompileTorchScript(defaultsScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := module.GetMethod("generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := method.Arguments()
+	if args[0].HasDefault {
+		t.Error("input should not have a default")
+	}
+	if !args[1].HasDefault || args[1].Default != int64(20) {
+		t.Error("wrong default for max_len", args[1].Default)
+	}
+	if !args[2].HasDefault || args[2].Default != float64(1) {
+		t.Error("wrong default for temperature", args[2].Default)
+	}
+	if !args[3].HasDefault || args[3].Default != false {
+		t.Error("wrong default for double", args[3].Default)
+	}
+
+	input, _ := NewTensor([]float32{1, 2})
+
+	res, err := method.RunWithKwargs([]interface{}{input}, map[string]interface{}{"temperature": 2.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.(*Tensor).Value(), []float32{22, 24}) {
+		t.Error("wrong result", res.(*Tensor).Value())
+	}
+
+	res, err = method.RunWithKwargs([]interface{}{input, 1}, map[string]interface{}{"double": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.(*Tensor).Value(), []float32{4, 6}) {
+		t.Error("wrong result", res.(*Tensor).Value())
+	}
+
+	res, err = method.Run(input, 0, 3.0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.(*Tensor).Value(), []float32{3, 6}) {
+		t.Error("wrong result", res.(*Tensor).Value())
+	}
+
+	errorCases := []struct {
+		args   []interface{}
+		kwargs map[string]interface{}
+		msg    string
+	}{
+		{nil, nil, "missing arguments [input]"},
+		{[]interface{}{input}, map[string]interface{}{"top_k": 1}, "unexpected keyword argument top_k"},
+		{[]interface{}{input}, map[string]interface{}{"input": input}, "multiple values for argument input"},
+		{[]interface{}{input, 1, 1.0, true, 1}, nil, "takes 4 positional arguments but 5 were given"},
+	}
+
+	for _, c := range errorCases {
+		_, err := method.RunWithKwargs(c.args, c.kwargs)
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("expected error containing %q but got %v", c.msg, err)
+		}
+	}
+
+	_, err = method.RunWithKwargs([]interface{}{input}, map[string]interface{}{"temperature": struct{}{}})
+	if !errors.Is(err, UnsupportedTypeError) {
+		t.Error("conversion errors should be wrapped but got", err)
+	}
+}
+
+func Test_MethodSchema(t *testing.T) {
+	module, err := CompileTorchScript(`
+def pick(inputs: List[Tensor], index: Optional[int], pair: Tuple[Tensor, float], scale: float = 2.0):
+	return (inputs[0] * scale, pair[1])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := module.GetMethod("pick")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := method.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(schema, "pick(") || !strings.Contains(schema, "Tensor[] inputs") {
+		t.Error("wrong schema", schema)
+	}
+
+	expected := []Type{
+		ListType{Elem: TensorType{}},
+		OptionalType{Elem: IntType{}},
+		TupleType{Elems: []Type{TensorType{}, FloatType{}}},
+		FloatType{},
+	}
+
+	args := method.Arguments()
+	if len(args) != len(expected) {
+		t.Fatal("wrong number of arguments", args)
+	}
+	for i, arg := range args {
+		if !reflect.DeepEqual(arg.TypeInfo, expected[i]) {
+			t.Errorf("wrong type for %s: %v", arg.Name, arg.TypeInfo)
+		}
+		if arg.KwargOnly {
+			t.Errorf("%s should not be kwarg-only", arg.Name)
+		}
+	}
+	if !args[3].HasDefault || args[3].Default != 2.0 {
+		t.Error("wrong default for scale", args[3].Default)
+	}
+
+	returns := method.Returns()
+	if len(returns) != 1 || !reflect.DeepEqual(returns[0].TypeInfo, TupleType{Elems: []Type{TensorType{}, FloatType{}}}) {
+		t.Error("wrong returns", returns)
+	}
+}
+
+func Test_IntrospectionE(t *testing.T) {
+	module, err := CompileTorchScript(defaultsScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := module.GetMethodNamesE()
+	if err != nil || !reflect.DeepEqual(names, module.GetMethodNames()) {
+		t.Error("wrong method names", names, err)
+	}
+
+	method, err := module.GetMethod("generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := method.ArgumentsE()
+	if err != nil || !reflect.DeepEqual(args, method.Arguments()) {
+		t.Error("wrong arguments", args, err)
+	}
+
+	returns, err := method.ReturnsE()
+	if err != nil || !reflect.DeepEqual(returns, method.Returns()) {
+		t.Error("wrong returns", returns, err)
+	}
+}
+
+func Test_ScalarReturn(t *testing.T) {
+	module, err := CompileTorchScript(`
+def stats(a, n: int):
+	return (a.numel() + n, float(n) / 2, n > 1)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := NewTensor([]float32{1, 2, 3})
+	res, err := module.RunMethod("stats", a, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res, Tuple{int64(6), 1.5, true}) {
+		t.Error("wrong result", res)
+	}
+}
+
+func Test_UnsupportedReturn(t *testing.T) {
+	module, err := CompileTorchScript(`
+def split(a):
+	return [a, a]
+
+def pair(a):
+	return (a, [a])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := NewTensor([]float32{1, 2, 3})
+
+	for _, method := range []string{"split", "pair"} {
+		res, err := module.RunMethod(method, a)
+		if !errors.Is(err, UnsupportedTypeError) {
+			t.Errorf("%s: expected an unsupported type error but got %v (%v)", method, err, res)
+		}
+	}
+}
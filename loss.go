@@ -0,0 +1,72 @@
+package torch
+
+// #include "torch.hpp"
+import "C"
+import (
+	"fmt"
+	"runtime"
+)
+
+// Reduction specifies the reduction applied to the output of a loss function
+type Reduction int
+
+const (
+	// ReductionNone no reduction is applied, loss is returned per element
+	ReductionNone Reduction = 0
+	// ReductionMean the sum of the output is divided by the number of elements
+	ReductionMean Reduction = 1
+	// ReductionSum the output is summed
+	ReductionSum Reduction = 2
+)
+
+// CrossEntropy computes the cross entropy loss between input logits (N, C) and target class indices (N)
+func CrossEntropy(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossCrossEntropy, input, target, reduction)
+}
+
+// NLLLoss computes the negative log likelihood loss between input log-probabilities (N, C) and target class indices (N)
+func NLLLoss(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossNLL, input, target, reduction)
+}
+
+// MSELoss computes the mean squared error between each element of input and target
+func MSELoss(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossMSE, input, target, reduction)
+}
+
+// L1Loss computes the mean absolute error between each element of input and target
+func L1Loss(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossL1, input, target, reduction)
+}
+
+// SmoothL1Loss computes the Huber loss between each element of input and target
+func SmoothL1Loss(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossSmoothL1, input, target, reduction)
+}
+
+// BCEWithLogits computes binary cross entropy between input logits and target probabilities
+func BCEWithLogits(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossBCEWithLogits, input, target, reduction)
+}
+
+// KLDiv computes the Kullback-Leibler divergence between input log-probabilities and target probabilities
+func KLDiv(input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	return loss(C.Torch_LossKLDiv, input, target, reduction)
+}
+
+func loss(typ C.Torch_LossType, input, target *Tensor, reduction Reduction) (*Tensor, error) {
+	if reduction < ReductionNone || reduction > ReductionSum {
+		return nil, fmt.Errorf("invalid reduction %d", int(reduction))
+	}
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_Loss(typ, input.context, target.context, C.int(reduction), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	runtime.KeepAlive(input)
+	runtime.KeepAlive(target)
+
+	return tensorWithContext(ctx), nil
+}
@@ -0,0 +1,97 @@
+package torch
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_Losses(t *testing.T) {
+	input, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	target, _ := NewTensor([][]float32{{1, 2}, {3, 6}})
+	classes, _ := NewTensor([]int64{1, 0})
+
+	tests := []struct {
+		name     string
+		loss     func(input, target *Tensor, reduction Reduction) (*Tensor, error)
+		target   *Tensor
+		expected float32
+	}{
+		{"MSELoss", MSELoss, target, 1},
+		{"L1Loss", L1Loss, target, 0.5},
+		{"SmoothL1Loss", SmoothL1Loss, target, 0.375},
+		{"CrossEntropy", CrossEntropy, classes, 0.8132617},
+		{"NLLLoss", NLLLoss, classes, -2.5},
+		{"KLDiv", KLDiv, target, -5.641828},
+	}
+
+	for _, test := range tests {
+		res, err := test.loss(input, test.target, ReductionMean)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if len(res.Shape()) != 0 {
+			t.Errorf("%s: mean reduction should return a scalar but got shape %v", test.name, res.Shape())
+		}
+
+		if val := res.Value().(float32); math.Abs(float64(val-test.expected)) > 1e-5 {
+			t.Errorf("%s: expected %v but got %v", test.name, test.expected, val)
+		}
+	}
+}
+
+func Test_LossReductionNone(t *testing.T) {
+	input, _ := NewTensor([]float32{0, 0})
+	target, _ := NewTensor([]float32{1, 0})
+
+	res, err := BCEWithLogits(input, target, ReductionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := res.Value().([]float32)
+	if len(val) != 2 || math.Abs(float64(val[0])-math.Ln2) > 1e-5 {
+		t.Error("wrong loss returned", val)
+	}
+}
+
+func Test_LossBackward(t *testing.T) {
+	input, _ := NewTensor([]float32{1, 2})
+	input.SetRequiresGrad(true)
+	target, _ := NewTensor([]float32{0, 0})
+
+	res, err := MSELoss(input, target, ReductionSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.Backward(); err != nil {
+		t.Fatal(err)
+	}
+
+	grad := input.Grad().Value().([]float32)
+	if grad[0] != 2 || grad[1] != 4 {
+		t.Error("wrong gradient", grad)
+	}
+}
+
+func Test_LossShapeMismatch(t *testing.T) {
+	input, _ := NewTensor([]float32{1, 2})
+	classes, _ := NewTensor([]int64{1, 0, 1})
+
+	if _, err := CrossEntropy(input, classes, ReductionMean); err == nil {
+		t.Error("should return an error")
+	}
+}
+
+func Test_LossInvalidReduction(t *testing.T) {
+	input, _ := NewTensor([]float32{1, 2})
+	target, _ := NewTensor([]float32{0, 0})
+
+	for _, reduction := range []Reduction{-1, 3} {
+		if _, err := MSELoss(input, target, reduction); err == nil {
+			t.Errorf("should return an error for reduction %d", reduction)
+		}
+	}
+}
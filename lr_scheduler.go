@@ -0,0 +1,274 @@
+package torch
+
+import (
+	"fmt"
+	"math"
+)
+
+// LRScheduler adjusts the learning rate of an Optimizer as training progresses
+type LRScheduler interface {
+	// Step advances the scheduler by one epoch (or iteration) and updates the optimizer learning rate
+	Step() error
+	// LearningRate returns the learning rate computed by the last step
+	LearningRate() float64
+	// StateDict returns the scheduler state so that it can be saved together with the optimizer
+	StateDict() map[string]float64
+	// LoadStateDict restores the scheduler state returned by StateDict
+	LoadStateDict(state map[string]float64) error
+}
+
+// lrScheduler contains the state shared by all schedulers. Concrete schedulers
+// provide a closed form learning rate for a given epoch.
+type lrScheduler struct {
+	optimizer *Optimizer
+	baseLR    float64
+	lastEpoch int
+	lastLR    float64
+	lrAt      func(epoch int) float64
+}
+
+func newLRScheduler(optimizer *Optimizer, lrAt func(epoch int) float64) lrScheduler {
+	return lrScheduler{
+		optimizer: optimizer,
+		lrAt:      lrAt,
+	}
+}
+
+// start reads the base learning rate from the optimizer and applies the learning rate of the first epoch
+func (s *lrScheduler) start() error {
+	baseLR, err := s.optimizer.LearningRateE()
+	if err != nil {
+		return err
+	}
+
+	s.baseLR = baseLR
+	return s.apply()
+}
+
+func (s *lrScheduler) apply() error {
+	lr := s.lrAt(s.lastEpoch)
+	if err := s.optimizer.SetLearningRate(lr); err != nil {
+		return err
+	}
+
+	s.lastLR = lr
+	return nil
+}
+
+// Step advances the scheduler by one epoch and updates the optimizer learning rate. The
+// scheduler is not advanced if the learning rate can not be updated.
+func (s *lrScheduler) Step() error {
+	s.lastEpoch++
+	if err := s.apply(); err != nil {
+		s.lastEpoch--
+		return err
+	}
+
+	return nil
+}
+
+// LearningRate returns the learning rate computed by the last step
+func (s *lrScheduler) LearningRate() float64 {
+	return s.lastLR
+}
+
+// LastEpoch returns the number of steps taken
+func (s *lrScheduler) LastEpoch() int {
+	return s.lastEpoch
+}
+
+// StateDict returns the scheduler state
+func (s *lrScheduler) StateDict() map[string]float64 {
+	return map[string]float64{
+		"base_lr":    s.baseLR,
+		"last_epoch": float64(s.lastEpoch),
+	}
+}
+
+// LoadStateDict restores the scheduler state and updates the optimizer learning rate accordingly
+func (s *lrScheduler) LoadStateDict(state map[string]float64) error {
+	baseLR, ok := state["base_lr"]
+	if !ok {
+		return fmt.Errorf("scheduler state is missing key %q", "base_lr")
+	}
+	lastEpoch, ok := state["last_epoch"]
+	if !ok {
+		return fmt.Errorf("scheduler state is missing key %q", "last_epoch")
+	}
+
+	s.baseLR = baseLR
+	s.lastEpoch = int(lastEpoch)
+
+	return s.apply()
+}
+
+// StepLR decays the learning rate by Gamma every StepSize epochs
+type StepLR struct {
+	lrScheduler
+	StepSize int
+	Gamma    float64
+}
+
+// NewStepLR returns a StepLR scheduler for given optimizer. StepSize must be positive.
+func NewStepLR(optimizer *Optimizer, stepSize int, gamma float64) (*StepLR, error) {
+	if stepSize <= 0 {
+		return nil, fmt.Errorf("StepLR step size must be positive but got %d", stepSize)
+	}
+
+	s := &StepLR{StepSize: stepSize, Gamma: gamma}
+	s.lrScheduler = newLRScheduler(optimizer, func(epoch int) float64 {
+		return s.baseLR * math.Pow(s.Gamma, float64(epoch/s.StepSize))
+	})
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ExponentialLR decays the learning rate by Gamma every epoch
+type ExponentialLR struct {
+	lrScheduler
+	Gamma float64
+}
+
+// NewExponentialLR returns an ExponentialLR scheduler for given optimizer
+func NewExponentialLR(optimizer *Optimizer, gamma float64) (*ExponentialLR, error) {
+	s := &ExponentialLR{Gamma: gamma}
+	s.lrScheduler = newLRScheduler(optimizer, func(epoch int) float64 {
+		return s.baseLR * math.Pow(s.Gamma, float64(epoch))
+	})
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CosineAnnealingLR anneals the learning rate from the initial value to EtaMin following a cosine curve over TMax epochs
+type CosineAnnealingLR struct {
+	lrScheduler
+	TMax   int
+	EtaMin float64
+}
+
+// NewCosineAnnealingLR returns a CosineAnnealingLR scheduler for given optimizer. TMax must be positive.
+func NewCosineAnnealingLR(optimizer *Optimizer, tMax int, etaMin float64) (*CosineAnnealingLR, error) {
+	if tMax <= 0 {
+		return nil, fmt.Errorf("CosineAnnealingLR TMax must be positive but got %d", tMax)
+	}
+
+	s := &CosineAnnealingLR{TMax: tMax, EtaMin: etaMin}
+	s.lrScheduler = newLRScheduler(optimizer, func(epoch int) float64 {
+		return s.EtaMin + (s.baseLR-s.EtaMin)*(1+math.Cos(math.Pi*float64(epoch)/float64(s.TMax)))/2
+	})
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AnnealStrategy annealing strategy used by OneCycleLR
+type AnnealStrategy int
+
+const (
+	// AnnealCos cosine annealing
+	AnnealCos AnnealStrategy = iota
+	// AnnealLinear linear annealing
+	AnnealLinear
+)
+
+// OneCycleLROptions options for the OneCycleLR scheduler. Zero values of PctStart, DivFactor
+// and FinalDivFactor are replaced with PyTorch defaults (0.3, 25 and 1e4)
+type OneCycleLROptions struct {
+	MaxLR          float64
+	TotalSteps     int
+	PctStart       float64
+	AnnealStrategy AnnealStrategy
+	DivFactor      float64
+	FinalDivFactor float64
+}
+
+// OneCycleLR implements the 1cycle policy: the learning rate is increased from MaxLR/DivFactor
+// to MaxLR and then annealed down to MaxLR/(DivFactor*FinalDivFactor). OneCycleLR should be
+// stepped after every batch.
+type OneCycleLR struct {
+	lrScheduler
+	OneCycleLROptions
+}
+
+// NewOneCycleLR returns a OneCycleLR scheduler for given optimizer. TotalSteps must be large
+// enough for both the increasing and the annealing phase to last more than one step.
+func NewOneCycleLR(optimizer *Optimizer, opts OneCycleLROptions) (*OneCycleLR, error) {
+	if opts.PctStart == 0 {
+		opts.PctStart = 0.3
+	}
+	if opts.DivFactor == 0 {
+		opts.DivFactor = 25
+	}
+	if opts.FinalDivFactor == 0 {
+		opts.FinalDivFactor = 1e4
+	}
+	if opts.PctStart < 0 || opts.PctStart > 1 {
+		return nil, fmt.Errorf("OneCycleLR PctStart must be between 0 and 1 but got %v", opts.PctStart)
+	}
+	if upSteps, downSteps := opts.phaseSteps(); upSteps <= 0 || downSteps <= 0 {
+		return nil, fmt.Errorf("OneCycleLR TotalSteps %d is too small for PctStart %v", opts.TotalSteps, opts.PctStart)
+	}
+
+	s := &OneCycleLR{OneCycleLROptions: opts}
+	s.lrScheduler = newLRScheduler(optimizer, s.learningRateAt)
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *OneCycleLR) learningRateAt(epoch int) float64 {
+	initialLR := s.MaxLR / s.DivFactor
+	minLR := initialLR / s.FinalDivFactor
+
+	upSteps, downSteps := s.phaseSteps()
+
+	step := float64(epoch)
+	if step <= upSteps {
+		return s.anneal(initialLR, s.MaxLR, step/upSteps)
+	}
+
+	return s.anneal(s.MaxLR, minLR, math.Min((step-upSteps)/downSteps, 1))
+}
+
+// phaseSteps returns the number of steps of the increasing and the annealing phase
+func (opts OneCycleLROptions) phaseSteps() (upSteps, downSteps float64) {
+	upSteps = opts.PctStart*float64(opts.TotalSteps) - 1
+	downSteps = float64(opts.TotalSteps) - 1 - upSteps
+	return upSteps, downSteps
+}
+
+func (s *OneCycleLR) anneal(start, end, pct float64) float64 {
+	if s.AnnealStrategy == AnnealLinear {
+		return (end-start)*pct + start
+	}
+
+	return end + (start-end)/2*(math.Cos(math.Pi*pct)+1)
+}
+
+// LinearWarmupLR linearly increases the learning rate from StartFactor*lr to lr over WarmupSteps steps
+type LinearWarmupLR struct {
+	lrScheduler
+	WarmupSteps int
+	StartFactor float64
+}
+
+// NewLinearWarmupLR returns a LinearWarmupLR scheduler for given optimizer
+func NewLinearWarmupLR(optimizer *Optimizer, warmupSteps int, startFactor float64) (*LinearWarmupLR, error) {
+	s := &LinearWarmupLR{WarmupSteps: warmupSteps, StartFactor: startFactor}
+	s.lrScheduler = newLRScheduler(optimizer, func(epoch int) float64 {
+		if epoch >= s.WarmupSteps {
+			return s.baseLR
+		}
+		return s.baseLR * (s.StartFactor + (1-s.StartFactor)*float64(epoch)/float64(s.WarmupSteps))
+	})
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
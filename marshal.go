@@ -0,0 +1,570 @@
+package torch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+)
+
+// Tensors are encoded in binary as:
+//
+//	magic "GTNS" | version (1 byte) | DType (1 byte) | rank (uvarint) | sizes (uvarint each) | data
+//
+// with the data in little-endian byte order and C (row-major) order. Tuples are encoded as:
+//
+//	magic "GTUP" | version (1 byte) | length (uvarint) | elements
+//
+// where each element is a tag byte followed by the value: a length prefixed (uvarint) tensor,
+// a tuple (length and elements), a varint int64, a little-endian float64, a bool byte or nothing for nil.
+var (
+	tensorMagic = []byte("GTNS")
+	tupleMagic  = []byte("GTUP")
+)
+
+const marshalVersion = 1
+
+// maxTupleDepth is the deepest nesting of tuples decoded so that crafted input can not exhaust the stack
+const maxTupleDepth = 100
+
+const (
+	tupleTagNil byte = iota
+	tupleTagTensor
+	tupleTagTuple
+	tupleTagInt
+	tupleTagFloat
+	tupleTagBool
+)
+
+// tensorJSON is the JSON representation of a tensor. Data holds the elements in C order as JSON
+// numbers (or "NaN", "Infinity" and "-Infinity" strings for non finite floats).
+type tensorJSON struct {
+	DType string            `json:"dtype"`
+	Shape []int64           `json:"shape"`
+	Data  []json.RawMessage `json:"data"`
+}
+
+// MarshalJSON encodes the tensor as {"dtype": "float32", "shape": [2], "data": [1, 2]}
+func (t *Tensor) MarshalJSON() ([]byte, error) {
+	dt, shape, data, err := t.marshalData()
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(typeOf(dt, nil).Size())
+	elems := make([]json.RawMessage, len(data)/size)
+	for i := range elems {
+		elem := data[i*size : (i+1)*size]
+
+		var s string
+		switch dt {
+		case Byte:
+			s = strconv.FormatUint(uint64(elem[0]), 10)
+		case Char:
+			s = strconv.FormatInt(int64(int8(elem[0])), 10)
+		case Int:
+			s = strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(elem))), 10)
+		case Long:
+			s = strconv.FormatInt(int64(binary.LittleEndian.Uint64(elem)), 10)
+		case Float:
+			s = formatJSONFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(elem))), 32)
+		case Double:
+			s = formatJSONFloat(math.Float64frombits(binary.LittleEndian.Uint64(elem)), 64)
+		}
+		elems[i] = json.RawMessage(s)
+	}
+
+	return json.Marshal(tensorJSON{
+		DType: dt.String(),
+		Shape: shape,
+		Data:  elems,
+	})
+}
+
+// UnmarshalJSON decodes a tensor encoded with MarshalJSON
+func (t *Tensor) UnmarshalJSON(b []byte) error {
+	var v tensorJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	dt, ok := parseDType(v.DType)
+	if !ok {
+		return newError(UnsupportedTypeError, "unsupported dtype %q", v.DType)
+	}
+	if err := checkMarshalShape(v.Shape, int64(len(v.Data))); err != nil {
+		return err
+	}
+
+	size := int(typeOf(dt, nil).Size())
+	data := make([]byte, len(v.Data)*size)
+	for i, raw := range v.Data {
+		elem := data[i*size : (i+1)*size]
+		s := string(raw)
+
+		var err error
+		switch dt {
+		case Byte:
+			var n uint64
+			n, err = strconv.ParseUint(s, 10, 8)
+			elem[0] = byte(n)
+		case Char:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 8)
+			elem[0] = byte(int8(n))
+		case Int:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 32)
+			binary.LittleEndian.PutUint32(elem, uint32(int32(n)))
+		case Long:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 64)
+			binary.LittleEndian.PutUint64(elem, uint64(n))
+		case Float:
+			var f float64
+			f, err = parseJSONFloat(s, 32)
+			binary.LittleEndian.PutUint32(elem, math.Float32bits(float32(f)))
+		case Double:
+			var f float64
+			f, err = parseJSONFloat(s, 64)
+			binary.LittleEndian.PutUint64(elem, math.Float64bits(f))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s element %s at %d: %w", dt, s, i, err)
+		}
+	}
+
+	return t.unmarshalData(data, v.Shape, dt)
+}
+
+// MarshalBinary encodes the tensor with a header describing its data type and shape (see UnmarshalBinary)
+func (t *Tensor) MarshalBinary() ([]byte, error) {
+	dt, shape, data, err := t.marshalData()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, len(tensorMagic)+2+binary.MaxVarintLen64*(len(shape)+1)+len(data))
+	buf = append(buf, tensorMagic...)
+	buf = append(buf, marshalVersion, byte(dt))
+	buf = appendUvarint(buf, uint64(len(shape)))
+	for _, size := range shape {
+		buf = appendUvarint(buf, uint64(size))
+	}
+	buf = append(buf, data...)
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a tensor encoded with MarshalBinary
+func (t *Tensor) UnmarshalBinary(b []byte) error {
+	headerLen := len(tensorMagic) + 2
+	if len(b) < headerLen {
+		return errors.New("tensor data is too short")
+	}
+	if !bytes.Equal(b[:len(tensorMagic)], tensorMagic) {
+		return fmt.Errorf("invalid tensor magic %q", b[:len(tensorMagic)])
+	}
+	if version := b[len(tensorMagic)]; version != marshalVersion {
+		return fmt.Errorf("unsupported tensor encoding version %d", version)
+	}
+
+	dt := DType(b[len(tensorMagic)+1])
+	if !supportedDType(dt) {
+		return newError(UnsupportedTypeError, "unsupported DType %s", dt)
+	}
+
+	r := bytes.NewReader(b[headerLen:])
+	rank, err := binary.ReadUvarint(r)
+	if err != nil || rank > uint64(r.Len()) {
+		return errors.New("invalid tensor rank")
+	}
+
+	shape := make([]int64, rank)
+	for i := range shape {
+		size, err := binary.ReadUvarint(r)
+		if err != nil || size > math.MaxInt64 {
+			return errors.New("invalid tensor size")
+		}
+		shape[i] = int64(size)
+	}
+
+	data := b[len(b)-r.Len():]
+	size := int64(typeOf(dt, nil).Size())
+	if int64(len(data))%size != 0 {
+		return fmt.Errorf("tensor data size %d is not a multiple of %s element size", len(data), dt)
+	}
+	if err := checkMarshalShape(shape, int64(len(data))/size); err != nil {
+		return err
+	}
+
+	return t.unmarshalData(data, shape, dt)
+}
+
+// GobEncode encodes the tensor for encoding/gob (see MarshalBinary)
+func (t *Tensor) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode decodes a tensor encoded with GobEncode
+func (t *Tensor) GobDecode(b []byte) error {
+	return t.UnmarshalBinary(b)
+}
+
+// marshalData returns the data type, shape and little-endian data of the tensor
+func (t *Tensor) marshalData() (DType, []int64, []byte, error) {
+	dt, err := t.DTypeE()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if !supportedDType(dt) {
+		return 0, nil, nil, newError(UnsupportedTypeError, "unsupported DType %s", dt)
+	}
+
+	shape, err := t.ShapeE()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	shape = append([]int64{}, shape...)
+
+	data, err := t.rawData()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if nativeEndian != binary.LittleEndian {
+		swapBytes(data, int(typeOf(dt, nil).Size()))
+	}
+
+	return dt, shape, data, nil
+}
+
+// unmarshalData replaces the tensor with a tensor of given little-endian data
+func (t *Tensor) unmarshalData(data []byte, shape []int64, dt DType) error {
+	if nativeEndian != binary.LittleEndian {
+		data = append([]byte(nil), data...)
+		swapBytes(data, int(typeOf(dt, nil).Size()))
+	}
+
+	tensor, err := newTensorFromBytes(data, shape, dt)
+	if err != nil {
+		return err
+	}
+
+	// The finalizer moves with the tensor data so that it is freed exactly once
+	runtime.SetFinalizer(tensor, nil)
+	runtime.SetFinalizer(t, nil)
+	if t.context != nil {
+		t.finalize()
+	}
+
+	*t = *tensor
+	runtime.SetFinalizer(t, (*Tensor).finalize)
+
+	return nil
+}
+
+// checkMarshalShape checks that shape has n elements
+func checkMarshalShape(shape []int64, n int64) error {
+	elems := int64(1)
+	for _, size := range shape {
+		if size < 0 {
+			return fmt.Errorf("invalid tensor shape %v", shape)
+		}
+		if size != 0 && elems > n/size {
+			return fmt.Errorf("tensor shape %v does not match %d elements", shape, n)
+		}
+		elems *= size
+	}
+	if elems != n {
+		return fmt.Errorf("tensor shape %v does not match %d elements", shape, n)
+	}
+	return nil
+}
+
+func parseDType(name string) (DType, bool) {
+	for dt, dtName := range dtypeStrings {
+		if dtName == name && supportedDType(dt) {
+			return dt, true
+		}
+	}
+	return 0, false
+}
+
+func formatJSONFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return `"NaN"`
+	case math.IsInf(f, 1):
+		return `"Infinity"`
+	case math.IsInf(f, -1):
+		return `"-Infinity"`
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+func parseJSONFloat(s string, bitSize int) (float64, error) {
+	switch s {
+	case `"NaN"`:
+		return math.NaN(), nil
+	case `"Infinity"`:
+		return math.Inf(1), nil
+	case `"-Infinity"`:
+		return math.Inf(-1), nil
+	}
+	return strconv.ParseFloat(s, bitSize)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// MarshalJSON encodes the tuple as a JSON array of its elements. Tensors are encoded as with
+// Tensor.MarshalJSON, nested tuples as arrays, and int64, float64, bool and nil as JSON values
+// (floats always with a decimal point or exponent so that they decode back to float64).
+func (t Tuple) MarshalJSON() ([]byte, error) {
+	elems := make([]json.RawMessage, len(t))
+	for i, elem := range t {
+		var err error
+		switch v := elem.(type) {
+		case *Tensor:
+			if v == nil {
+				elems[i] = json.RawMessage("null")
+				break
+			}
+			elems[i], err = v.MarshalJSON()
+		case Tuple:
+			elems[i], err = v.MarshalJSON()
+		case int64:
+			elems[i] = json.RawMessage(strconv.FormatInt(v, 10))
+		case int:
+			elems[i] = json.RawMessage(strconv.Itoa(v))
+		case float64:
+			s := formatJSONFloat(v, 64)
+			if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+				s += ".0"
+			}
+			elems[i] = json.RawMessage(s)
+		case bool:
+			elems[i] = json.RawMessage(strconv.FormatBool(v))
+		case nil:
+			elems[i] = json.RawMessage("null")
+		default:
+			err = newError(UnsupportedTypeError, "unsupported tuple element type %T", elem)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(elems)
+}
+
+// UnmarshalJSON decodes a tuple encoded with MarshalJSON. Integers decode as int64 and numbers with a
+// decimal point, an exponent or non finite values as float64.
+func (t *Tuple) UnmarshalJSON(b []byte) error {
+	return t.unmarshalJSON(b, 0)
+}
+
+func (t *Tuple) unmarshalJSON(b []byte, depth int) error {
+	if depth >= maxTupleDepth {
+		return fmt.Errorf("tuples are nested deeper than %d levels", maxTupleDepth)
+	}
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal(b, &elems); err != nil {
+		return err
+	}
+
+	tuple := make(Tuple, len(elems))
+	for i, raw := range elems {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			return fmt.Errorf("empty tuple element %d", i)
+		}
+
+		switch s := string(raw); {
+		case raw[0] == '{':
+			tensor := &Tensor{}
+			if err := tensor.UnmarshalJSON(raw); err != nil {
+				return err
+			}
+			tuple[i] = tensor
+		case raw[0] == '[':
+			var nested Tuple
+			if err := nested.unmarshalJSON(raw, depth+1); err != nil {
+				return err
+			}
+			tuple[i] = nested
+		case s == "null":
+			tuple[i] = nil
+		case s == "true" || s == "false":
+			tuple[i] = s == "true"
+		default:
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				tuple[i] = n
+				continue
+			}
+			f, err := parseJSONFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("invalid tuple element %s", s)
+			}
+			tuple[i] = f
+		}
+	}
+
+	*t = tuple
+	return nil
+}
+
+// MarshalBinary encodes the tuple and its elements (see UnmarshalBinary)
+func (t Tuple) MarshalBinary() ([]byte, error) {
+	buf := append([]byte{}, tupleMagic...)
+	buf = append(buf, marshalVersion)
+	return t.appendBinary(buf)
+}
+
+func (t Tuple) appendBinary(buf []byte) ([]byte, error) {
+	buf = appendUvarint(buf, uint64(len(t)))
+	for _, elem := range t {
+		switch v := elem.(type) {
+		case *Tensor:
+			if v == nil {
+				buf = append(buf, tupleTagNil)
+				break
+			}
+			data, err := v.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, tupleTagTensor)
+			buf = appendUvarint(buf, uint64(len(data)))
+			buf = append(buf, data...)
+		case Tuple:
+			var err error
+			buf = append(buf, tupleTagTuple)
+			if buf, err = v.appendBinary(buf); err != nil {
+				return nil, err
+			}
+		case int64:
+			buf = appendVarint(append(buf, tupleTagInt), v)
+		case int:
+			buf = appendVarint(append(buf, tupleTagInt), int64(v))
+		case float64:
+			var tmp [8]byte
+			binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+			buf = append(buf, tupleTagFloat)
+			buf = append(buf, tmp[:]...)
+		case bool:
+			buf = append(buf, tupleTagBool, byte(cBool(v)))
+		case nil:
+			buf = append(buf, tupleTagNil)
+		default:
+			return nil, newError(UnsupportedTypeError, "unsupported tuple element type %T", elem)
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a tuple encoded with MarshalBinary
+func (t *Tuple) UnmarshalBinary(b []byte) error {
+	if len(b) < len(tupleMagic)+1 || !bytes.Equal(b[:len(tupleMagic)], tupleMagic) {
+		return errors.New("invalid tuple magic")
+	}
+	if version := b[len(tupleMagic)]; version != marshalVersion {
+		return fmt.Errorf("unsupported tuple encoding version %d", version)
+	}
+
+	r := bytes.NewReader(b[len(tupleMagic)+1:])
+	tuple, err := readTuple(r, 0)
+	if err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%d unexpected bytes after tuple", r.Len())
+	}
+
+	*t = tuple
+	return nil
+}
+
+func readTuple(r *bytes.Reader, depth int) (Tuple, error) {
+	if depth >= maxTupleDepth {
+		return nil, fmt.Errorf("tuples are nested deeper than %d levels", maxTupleDepth)
+	}
+
+	length, err := binary.ReadUvarint(r)
+	if err != nil || length > uint64(r.Len()) {
+		return nil, errors.New("invalid tuple length")
+	}
+
+	tuple := make(Tuple, length)
+	for i := range tuple {
+		tag, err := r.ReadByte()
+		if err != nil {
+			return nil, errors.New("tuple data is too short")
+		}
+
+		switch tag {
+		case tupleTagNil:
+		case tupleTagTensor:
+			size, err := binary.ReadUvarint(r)
+			if err != nil || size > uint64(r.Len()) {
+				return nil, errors.New("invalid tensor length in tuple")
+			}
+			data := make([]byte, size)
+			r.Read(data)
+
+			tensor := &Tensor{}
+			if err := tensor.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			tuple[i] = tensor
+		case tupleTagTuple:
+			if tuple[i], err = readTuple(r, depth+1); err != nil {
+				return nil, err
+			}
+		case tupleTagInt:
+			if tuple[i], err = binary.ReadVarint(r); err != nil {
+				return nil, errors.New("invalid int in tuple")
+			}
+		case tupleTagFloat:
+			var bits uint64
+			if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+				return nil, errors.New("invalid float in tuple")
+			}
+			tuple[i] = math.Float64frombits(bits)
+		case tupleTagBool:
+			v, err := r.ReadByte()
+			if err != nil {
+				return nil, errors.New("invalid bool in tuple")
+			}
+			tuple[i] = v != 0
+		default:
+			return nil, fmt.Errorf("invalid tuple element tag %d", tag)
+		}
+	}
+
+	return tuple, nil
+}
+
+// GobEncode encodes the tuple for encoding/gob (see MarshalBinary)
+func (t Tuple) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode decodes a tuple encoded with GobEncode
+func (t *Tuple) GobDecode(b []byte) error {
+	return t.UnmarshalBinary(b)
+}
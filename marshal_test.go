@@ -0,0 +1,300 @@
+package torch
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type marshalCodec struct {
+	name      string
+	marshal   func(t *Tensor) ([]byte, error)
+	unmarshal func(data []byte) (*Tensor, error)
+}
+
+var marshalCodecs = []marshalCodec{
+	{
+		"json",
+		func(t *Tensor) ([]byte, error) { return json.Marshal(t) },
+		func(data []byte) (*Tensor, error) {
+			var t *Tensor
+			err := json.Unmarshal(data, &t)
+			return t, err
+		},
+	},
+	{
+		"binary",
+		func(t *Tensor) ([]byte, error) { return t.MarshalBinary() },
+		func(data []byte) (*Tensor, error) {
+			t := &Tensor{}
+			err := t.UnmarshalBinary(data)
+			return t, err
+		},
+	},
+	{
+		"gob",
+		func(t *Tensor) ([]byte, error) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(t)
+			return buf.Bytes(), err
+		},
+		func(data []byte) (*Tensor, error) {
+			var t *Tensor
+			err := gob.NewDecoder(bytes.NewReader(data)).Decode(&t)
+			return t, err
+		},
+	},
+}
+
+func Test_TensorMarshalRoundTrip(t *testing.T) {
+	values := []interface{}{
+		[][]uint8{{0, 1, 255}, {2, 3, 4}},
+		[][]int8{{-128, 0, 127}, {1, 2, 3}},
+		[][]int32{{math.MinInt32, 0, math.MaxInt32}, {1, 2, 3}},
+		[][]int64{{math.MinInt64, 0, math.MaxInt64}, {1, 2, 3}},
+		[][]float32{{-1.5, 0, math.MaxFloat32}, {math.SmallestNonzeroFloat32, 0.1, 3}},
+		[][]float64{{-1.5, 0, math.MaxFloat64}, {math.SmallestNonzeroFloat64, 0.1, 3}},
+		float32(2.5),
+		[]int64{},
+	}
+
+	for _, codec := range marshalCodecs {
+		for _, value := range values {
+			tensor, err := NewTensor(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := codec.marshal(tensor)
+			if err != nil {
+				t.Errorf("%s: unable to marshal %v: %v", codec.name, tensor.DType(), err)
+				continue
+			}
+
+			decoded, err := codec.unmarshal(data)
+			if err != nil {
+				t.Errorf("%s: unable to unmarshal %v: %v", codec.name, tensor.DType(), err)
+				continue
+			}
+
+			if decoded.DType() != tensor.DType() || !reflect.DeepEqual(decoded.Shape(), tensor.Shape()) {
+				t.Errorf("%s: expected %v %v but got %v %v", codec.name, tensor.DType(), tensor.Shape(), decoded.DType(), decoded.Shape())
+			}
+			if !reflect.DeepEqual(decoded.Value(), value) {
+				t.Errorf("%s: expected %v but got %v", codec.name, value, decoded.Value())
+			}
+		}
+	}
+}
+
+func Test_TensorMarshalNonFinite(t *testing.T) {
+	tensor, _ := NewTensor([]float32{float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1))})
+
+	data, err := json.Marshal(tensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"dtype":"float32","shape":[3],"data":["NaN","Infinity","-Infinity"]}`; string(data) != expected {
+		t.Errorf("expected %s but got %s", expected, data)
+	}
+
+	var decoded *Tensor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	value := decoded.Value().([]float32)
+	if !math.IsNaN(float64(value[0])) || !math.IsInf(float64(value[1]), 1) || !math.IsInf(float64(value[2]), -1) {
+		t.Error("wrong value", value)
+	}
+}
+
+func Test_TensorUnmarshalInto(t *testing.T) {
+	a, _ := NewTensor([]float32{1, 2})
+	b, _ := NewTensor([]int64{3, 4, 5})
+
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unmarshaling into an existing tensor replaces it
+	if err := a.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Value(), []int64{3, 4, 5}) {
+		t.Error("wrong value", a.Value())
+	}
+}
+
+func Test_TensorUnmarshalErrors(t *testing.T) {
+	jsonCases := []string{
+		`{"dtype":"float16","shape":[1],"data":[1]}`,
+		`{"dtype":"float32","shape":[2],"data":[1]}`,
+		`{"dtype":"float32","shape":[-1],"data":[1]}`,
+		`{"dtype":"uint8","shape":[1],"data":[256]}`,
+		`{"dtype":"int64","shape":[1],"data":[1.5]}`,
+		`{"dtype":"float32","shape":[1],"data":["foo"]}`,
+	}
+
+	for _, c := range jsonCases {
+		var tensor *Tensor
+		if err := json.Unmarshal([]byte(c), &tensor); err == nil {
+			t.Errorf("expected an error for %s", c)
+		}
+	}
+
+	valid, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	data, _ := valid.MarshalBinary()
+
+	binaryCases := map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("XXXX"), data[4:]...),
+		"version":   append(append([]byte{}, data[:4]...), append([]byte{2}, data[5:]...)...),
+		"dtype":     append(append([]byte{}, data[:5]...), append([]byte{6}, data[6:]...)...),
+		"truncated": data[:len(data)-1],
+		"extra":     append(append([]byte{}, data...), 0, 0, 0, 0),
+		"rank":      append(append([]byte{}, data[:6]...), 0xff, 0xff, 0xff, 0xff, 0x0f),
+	}
+
+	for name, c := range binaryCases {
+		tensor := &Tensor{}
+		if err := tensor.UnmarshalBinary(c); err == nil {
+			t.Errorf("expected an error for %s data", name)
+		}
+	}
+}
+
+func Test_TupleMarshalRoundTrip(t *testing.T) {
+	a, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	b, _ := NewTensor([]uint8{5, 6})
+
+	tuple := Tuple{a, Tuple{b, int64(-7), 1.0, true, nil}, 2.5, math.Inf(-1), Tuple{}}
+
+	codecs := []struct {
+		name      string
+		marshal   func(t Tuple) ([]byte, error)
+		unmarshal func(data []byte) (Tuple, error)
+	}{
+		{
+			"json",
+			func(t Tuple) ([]byte, error) { return json.Marshal(t) },
+			func(data []byte) (Tuple, error) {
+				var t Tuple
+				err := json.Unmarshal(data, &t)
+				return t, err
+			},
+		},
+		{
+			"binary",
+			func(t Tuple) ([]byte, error) { return t.MarshalBinary() },
+			func(data []byte) (Tuple, error) {
+				var t Tuple
+				err := t.UnmarshalBinary(data)
+				return t, err
+			},
+		},
+		{
+			"gob",
+			func(t Tuple) ([]byte, error) {
+				var buf bytes.Buffer
+				err := gob.NewEncoder(&buf).Encode(struct{ Outputs Tuple }{t})
+				return buf.Bytes(), err
+			},
+			func(data []byte) (Tuple, error) {
+				var v struct{ Outputs Tuple }
+				err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+				return v.Outputs, err
+			},
+		},
+	}
+
+	for _, codec := range codecs {
+		data, err := codec.marshal(tuple)
+		if err != nil {
+			t.Errorf("%s: %v", codec.name, err)
+			continue
+		}
+
+		decoded, err := codec.unmarshal(data)
+		if err != nil {
+			t.Errorf("%s: %v", codec.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(tupleValues(decoded), tupleValues(tuple)) {
+			t.Errorf("%s: expected %v but got %v", codec.name, tupleValues(tuple), tupleValues(decoded))
+		}
+	}
+
+	if _, err := json.Marshal(Tuple{"string"}); err == nil || !strings.Contains(err.Error(), "unsupported tuple element type string") {
+		t.Error("expected an error for unsupported elements but got", err)
+	}
+	if _, err := (Tuple{"string"}).MarshalBinary(); err == nil {
+		t.Error("expected an error for unsupported elements")
+	}
+}
+
+func Test_TupleUnmarshalDepth(t *testing.T) {
+	nested := func(levels int) Tuple {
+		tuple := Tuple{}
+		for i := 1; i < levels; i++ {
+			tuple = Tuple{tuple}
+		}
+		return tuple
+	}
+
+	for _, levels := range []int{maxTupleDepth, maxTupleDepth + 1} {
+		tuple := nested(levels)
+
+		data, err := json.Marshal(tuple)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON Tuple
+		err = json.Unmarshal(data, &fromJSON)
+		if tooDeep := levels > maxTupleDepth; tooDeep != (err != nil) {
+			t.Errorf("json: unexpected result for %d levels: %v", levels, err)
+		}
+
+		data, err = tuple.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromBinary Tuple
+		err = fromBinary.UnmarshalBinary(data)
+		if tooDeep := levels > maxTupleDepth; tooDeep != (err != nil) {
+			t.Errorf("binary: unexpected result for %d levels: %v", levels, err)
+		}
+	}
+
+	// Crafted input nesting a million tuples is rejected without recursing through all of them
+	data := append(append([]byte{}, tupleMagic...), marshalVersion)
+	data = append(data, bytes.Repeat([]byte{1, tupleTagTuple}, 1<<20)...)
+	data = append(data, 0)
+
+	var tuple Tuple
+	if err := tuple.UnmarshalBinary(data); err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Error("expected an error for deeply nested tuples but got", err)
+	}
+}
+
+// tupleValues replaces tensors in a tuple with their dtypes and values for comparison
+func tupleValues(tuple Tuple) []interface{} {
+	values := make([]interface{}, len(tuple))
+	for i, elem := range tuple {
+		switch v := elem.(type) {
+		case *Tensor:
+			values[i] = []interface{}{v.DType(), v.Value()}
+		case Tuple:
+			values[i] = tupleValues(v)
+		default:
+			values[i] = v
+		}
+	}
+	return values
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package torch
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+)
+
+func mapFile(f *os.File, size int) (unsafe.Pointer, error) {
+	return nil, errors.New("memory mapping is not supported on this platform")
+}
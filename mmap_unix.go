@@ -0,0 +1,33 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package torch
+
+/*
+#include <stddef.h>
+#include <sys/mman.h>
+
+static void* torch_map_file(int fd, size_t size) {
+	void* data = mmap(NULL, size, PROT_READ | PROT_WRITE, MAP_PRIVATE, fd, 0);
+	return data == MAP_FAILED ? NULL : data;
+}
+*/
+import "C"
+import (
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// mapFile maps given file into memory. Mapping is private (copy-on-write) so that tensors
+// created on top of it can be modified without changing the file. The mapping is unmapped by
+// LibTorch once it is no longer used (see Torch_NewMappedFile).
+func mapFile(f *os.File, size int) (unsafe.Pointer, error) {
+	data, err := C.torch_map_file(C.int(f.Fd()), C.size_t(size))
+	runtime.KeepAlive(f)
+	if data == nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,360 @@
+package torch
+
+// #include "torch.hpp"
+// #include <stdlib.h>
+import "C"
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// Module is a neural network layer (or a composition of layers) that can be trained from Go
+type Module interface {
+	// Forward executes forward propagation for given input
+	Forward(input *Tensor) (*Tensor, error)
+	// Parameters returns all trainable parameters of the module
+	Parameters() []*Tensor
+	// Train sets the module to training (true) or evaluation (false) mode
+	Train(on bool)
+}
+
+// nnModule wraps a torch::nn::Module created by LibTorch
+type nnModule struct {
+	context C.Torch_NNModuleContext
+}
+
+func nnModuleWithContext(ctx C.Torch_NNModuleContext) *nnModule {
+	mod := &nnModule{context: ctx}
+	runtime.SetFinalizer(mod, (*nnModule).finalize)
+	return mod
+}
+
+// Forward executes forward propagation for given input
+func (m *nnModule) Forward(input *Tensor) (*Tensor, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNModuleForward(m.context, input.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	runtime.KeepAlive(input)
+
+	return tensorWithContext(ctx), nil
+}
+
+// NamedParameters returns all trainable parameters of the module by name. It panics if LibTorch fails (see NamedParametersE).
+func (m *nnModule) NamedParameters() map[string]*Tensor {
+	params, err := m.NamedParametersE()
+	if err != nil {
+		panic(err)
+	}
+	return params
+}
+
+// NamedParametersE returns all trainable parameters of the module by name
+func (m *nnModule) NamedParametersE() (map[string]*Tensor, error) {
+	resPtr, resSize, err := m.namedParameters()
+	if err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(resPtr))
+
+	return convertNamedTensors(resPtr, resSize), nil
+}
+
+// Parameters returns all trainable parameters of the module. It panics if LibTorch fails (see ParametersE).
+func (m *nnModule) Parameters() []*Tensor {
+	params, err := m.ParametersE()
+	if err != nil {
+		panic(err)
+	}
+	return params
+}
+
+// ParametersE returns all trainable parameters of the module
+func (m *nnModule) ParametersE() ([]*Tensor, error) {
+	resPtr, resSize, err := m.namedParameters()
+	if err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(resPtr))
+
+	resSlice := (*[1 << 30]C.Torch_NamedTensor)(unsafe.Pointer(resPtr))[:resSize:resSize]
+
+	params := make([]*Tensor, len(resSlice))
+	for i, param := range resSlice {
+		params[i] = tensorWithContext(param.tensor)
+		C.free(unsafe.Pointer(param.name))
+	}
+
+	return params, nil
+}
+
+func (m *nnModule) namedParameters() (*C.Torch_NamedTensor, C.ulong, error) {
+	var resSize C.ulong
+	var cErr C.Torch_Error
+	resPtr := C.Torch_NNModuleNamedParameters(m.context, &resSize, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, 0, err
+	}
+
+	return resPtr, resSize, nil
+}
+
+// Train sets the module to training (true) or evaluation (false) mode. It panics if LibTorch fails (see TrainE).
+func (m *nnModule) Train(on bool) {
+	if err := m.TrainE(on); err != nil {
+		panic(err)
+	}
+}
+
+// TrainE sets the module to training (true) or evaluation (false) mode
+func (m *nnModule) TrainE(on bool) error {
+	var cErr C.Torch_Error
+	C.Torch_NNModuleTrain(m.context, cBool(on), &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Eval sets the module to evaluation mode
+func (m *nnModule) Eval() {
+	m.Train(false)
+}
+
+func (m *nnModule) finalize() {
+	C.Torch_DeleteNNModule(m.context)
+}
+
+func convertNamedTensors(ptr *C.Torch_NamedTensor, size C.ulong) map[string]*Tensor {
+	slice := (*[1 << 30]C.Torch_NamedTensor)(unsafe.Pointer(ptr))[:size:size]
+
+	tensors := make(map[string]*Tensor, len(slice))
+	for _, named := range slice {
+		tensors[C.GoString(named.name)] = tensorWithContext(named.tensor)
+		C.free(unsafe.Pointer(named.name))
+	}
+
+	return tensors
+}
+
+// Linear applies a linear transformation y = xW^T + b
+type Linear struct {
+	*nnModule
+	InFeatures  int64
+	OutFeatures int64
+}
+
+// NewLinear returns a new Linear layer
+func NewLinear(inFeatures, outFeatures int64, bias bool) (*Linear, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNLinear(C.int64_t(inFeatures), C.int64_t(outFeatures), cBool(bias), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return &Linear{nnModuleWithContext(ctx), inFeatures, outFeatures}, nil
+}
+
+// Conv2d applies a 2D convolution over an input of shape (N, C, H, W)
+type Conv2d struct {
+	*nnModule
+	InChannels  int64
+	OutChannels int64
+	KernelSize  int64
+	Stride      int64
+	Padding     int64
+}
+
+// NewConv2d returns a new Conv2d layer
+func NewConv2d(inChannels, outChannels, kernelSize, stride, padding int64, bias bool) (*Conv2d, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNConv2d(
+		C.int64_t(inChannels),
+		C.int64_t(outChannels),
+		C.int64_t(kernelSize),
+		C.int64_t(stride),
+		C.int64_t(padding),
+		cBool(bias),
+		&cErr,
+	)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return &Conv2d{nnModuleWithContext(ctx), inChannels, outChannels, kernelSize, stride, padding}, nil
+}
+
+// BatchNorm2d applies batch normalization over an input of shape (N, C, H, W) and tracks running statistics
+type BatchNorm2d struct {
+	*nnModule
+	NumFeatures int64
+}
+
+// NewBatchNorm2d returns a new BatchNorm2d layer
+func NewBatchNorm2d(numFeatures int64, eps, momentum float64) (*BatchNorm2d, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNBatchNorm2d(C.int64_t(numFeatures), C.double(eps), C.double(momentum), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return &BatchNorm2d{nnModuleWithContext(ctx), numFeatures}, nil
+}
+
+// Embedding is a lookup table of NumEmbeddings vectors of size EmbeddingDim
+type Embedding struct {
+	*nnModule
+	NumEmbeddings int64
+	EmbeddingDim  int64
+}
+
+// NewEmbedding returns a new Embedding layer
+func NewEmbedding(numEmbeddings, embeddingDim int64) (*Embedding, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNEmbedding(C.int64_t(numEmbeddings), C.int64_t(embeddingDim), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return &Embedding{nnModuleWithContext(ctx), numEmbeddings, embeddingDim}, nil
+}
+
+// LayerNorm applies layer normalization over the last dimensions (NormalizedShape) of the input
+type LayerNorm struct {
+	*nnModule
+	NormalizedShape []int64
+}
+
+// NewLayerNorm returns a new LayerNorm layer
+func NewLayerNorm(normalizedShape []int64, eps float64, affine bool) (*LayerNorm, error) {
+	var shapePtr *C.int64_t
+	if len(normalizedShape) > 0 {
+		shapePtr = (*C.int64_t)(unsafe.Pointer(&normalizedShape[0]))
+	}
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNLayerNorm(shapePtr, C.int(len(normalizedShape)), C.double(eps), cBool(affine), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return &LayerNorm{nnModuleWithContext(ctx), append([]int64(nil), normalizedShape...)}, nil
+}
+
+// Dropout randomly zeroes elements of the input with probability P during training
+type Dropout struct {
+	*nnModule
+	P float64
+}
+
+// NewDropout returns a new Dropout layer
+func NewDropout(p float64) (*Dropout, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_NNDropout(C.double(p), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return &Dropout{nnModuleWithContext(ctx), p}, nil
+}
+
+// Sequential chains modules so that the output of each module is the input of the next one
+type Sequential struct {
+	Modules []Module
+}
+
+// NewSequential returns a new Sequential for given modules
+func NewSequential(modules ...Module) *Sequential {
+	return &Sequential{Modules: modules}
+}
+
+// Forward executes forward propagation of every module in order
+func (s *Sequential) Forward(input *Tensor) (*Tensor, error) {
+	output := input
+	for i, m := range s.Modules {
+		var err error
+		output, err = m.Forward(output)
+		if err != nil {
+			return nil, fmt.Errorf("sequential module %d: %w", i, err)
+		}
+	}
+
+	return output, nil
+}
+
+// ForwardContext executes forward propagation of every module in order like Forward but returns
+// ctx.Err() if ctx is done before any of the modules is run
+func (s *Sequential) ForwardContext(ctx context.Context, input *Tensor) (*Tensor, error) {
+	output := input
+	for i, m := range s.Modules {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var err error
+		output, err = m.Forward(output)
+		if err != nil {
+			return nil, fmt.Errorf("sequential module %d: %w", i, err)
+		}
+	}
+
+	return output, nil
+}
+
+// Parameters returns the parameters of all modules
+func (s *Sequential) Parameters() []*Tensor {
+	var params []*Tensor
+	for _, m := range s.Modules {
+		params = append(params, m.Parameters()...)
+	}
+
+	return params
+}
+
+// Train sets all modules to training (true) or evaluation (false) mode
+func (s *Sequential) Train(on bool) {
+	for _, m := range s.Modules {
+		m.Train(on)
+	}
+}
+
+// JITLayer adapts a JITModule method taking and returning a single tensor to the Module interface
+// so that loaded TorchScript backbones can be composed with layers defined in Go. JIT module
+// parameters are not trained.
+type JITLayer struct {
+	Module *JITModule
+	Method string
+}
+
+// NewJITLayer returns a JITLayer which runs the forward method of given module
+func NewJITLayer(module *JITModule) *JITLayer {
+	return &JITLayer{Module: module, Method: "forward"}
+}
+
+// Forward runs the JIT module method for given input
+func (l *JITLayer) Forward(input *Tensor) (*Tensor, error) {
+	res, err := l.Module.RunMethod(l.Method, input)
+	if err != nil {
+		return nil, err
+	}
+
+	output, ok := res.(*Tensor)
+	if !ok {
+		return nil, fmt.Errorf("method %s returned %T instead of a tensor", l.Method, res)
+	}
+
+	return output, nil
+}
+
+// Parameters returns nil as JIT module parameters are not trained
+func (l *JITLayer) Parameters() []*Tensor {
+	return nil
+}
+
+// Train is a no-op for JIT modules
+func (l *JITLayer) Train(on bool) {}
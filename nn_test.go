@@ -0,0 +1,189 @@
+package torch
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_Linear(t *testing.T) {
+	linear, err := NewLinear(3, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(linear.Parameters()) != 2 {
+		t.Error("linear layer should have weight and bias parameters")
+	}
+
+	params := linear.NamedParameters()
+	if w, ok := params["weight"]; !ok || w.Shape()[0] != 2 || w.Shape()[1] != 3 {
+		t.Error("wrong weight parameter", params)
+	}
+
+	input, _ := NewTensor([][]float32{{1, 2, 3}})
+	output, err := linear.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shape := output.Shape(); shape[0] != 1 || shape[1] != 2 {
+		t.Error("wrong output shape", shape)
+	}
+
+	_, err = linear.Forward(output)
+	if err == nil {
+		t.Error("should return an error for mismatching input size")
+	}
+}
+
+func Test_LayerNorm(t *testing.T) {
+	shape := []int64{2}
+	layerNorm, err := NewLayerNorm(shape, 1e-5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shape[0] = 3
+	if layerNorm.NormalizedShape[0] != 2 {
+		t.Error("normalized shape should not change with the caller's slice", layerNorm.NormalizedShape)
+	}
+
+	input, _ := NewTensor([][]float32{{1, 3}})
+	output, err := layerNorm.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := output.Value().([][]float32)
+	if val[0][0] > -0.99 || val[0][1] < 0.99 {
+		t.Error("wrong normalized output", val)
+	}
+}
+
+func Test_SequentialTraining(t *testing.T) {
+	linear, _ := NewLinear(2, 1, true)
+	dropout, _ := NewDropout(0.5)
+	model := NewSequential(linear, dropout)
+
+	if len(model.Parameters()) != 2 {
+		t.Error("wrong number of parameters", len(model.Parameters()))
+	}
+
+	opt, err := NewSGD(model.Parameters(), SGDOptions{LearningRate: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	target, _ := NewTensor([][]float32{{1}, {2}})
+
+	model.Train(false)
+
+	var losses []float32
+	for i := 0; i < 10; i++ {
+		opt.ZeroGrad()
+		output, err := model.Forward(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loss, err := MSELoss(output, target, ReductionMean)
+		if err != nil {
+			t.Fatal(err)
+		}
+		losses = append(losses, loss.Value().(float32))
+
+		if err := loss.Backward(); err != nil {
+			t.Fatal(err)
+		}
+		if err := opt.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if losses[len(losses)-1] >= losses[0] {
+		t.Error("loss should decrease during training", losses)
+	}
+}
+
+func Test_SequentialError(t *testing.T) {
+	linear, _ := NewLinear(3, 2, true)
+	model := NewSequential(linear)
+
+	input, _ := NewTensor([][]float32{{1, 2}})
+	_, err := model.Forward(input)
+
+	var torchErr *Error
+	if !errors.As(err, &torchErr) {
+		t.Error("module errors should be wrapped but got", err)
+	}
+}
+
+// cancelModule cancels a context when it is run
+type cancelModule struct {
+	cancel func()
+	calls  int
+}
+
+func (m *cancelModule) Forward(input *Tensor) (*Tensor, error) {
+	m.calls++
+	m.cancel()
+	return input, nil
+}
+
+func (m *cancelModule) Parameters() []*Tensor { return nil }
+
+func (m *cancelModule) Train(on bool) {}
+
+func Test_SequentialForwardContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &cancelModule{cancel: cancel}
+	second := &cancelModule{cancel: cancel}
+	model := NewSequential(first, second)
+
+	input, _ := NewTensor([][]float32{{1, 2}})
+	if _, err := model.ForwardContext(ctx, input); err != context.Canceled {
+		t.Error("expected context.Canceled", err)
+	}
+	if first.calls != 1 || second.calls != 0 {
+		t.Error("modules should not be run after ctx is done", first.calls, second.calls)
+	}
+
+	output, err := model.ForwardContext(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != input {
+		t.Error("wrong output", output)
+	}
+}
+
+func Test_JITLayer(t *testing.T) {
+	backbone, err := CompileTorchScript(`
+def forward(x):
+	return x * 2
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head, _ := NewLinear(2, 1, false)
+	model := NewSequential(NewJITLayer(backbone), head)
+
+	if len(model.Parameters()) != 1 {
+		t.Error("only head parameters should be returned", len(model.Parameters()))
+	}
+
+	input, _ := NewTensor([][]float32{{1, 2}})
+	output, err := model.Forward(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shape := output.Shape(); shape[0] != 1 || shape[1] != 1 {
+		t.Error("wrong output shape", shape)
+	}
+}
@@ -0,0 +1,409 @@
+package torch
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var npyMagic = []byte("\x93NUMPY")
+
+const (
+	// npyMaxHeaderLen limits the header length read from version 2 and 3 files
+	npyMaxHeaderLen = 1 << 20
+	// npyReadChunkSize is the largest buffer allocated before data has been read so that
+	// sizes from malformed headers can not allocate more memory than the data contains
+	npyReadChunkSize = 1 << 24
+)
+
+var (
+	npyDescrRegexp        = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	npyFortranOrderRegexp = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShapeRegexp        = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// ReadNpy reads a tensor from NumPy .npy data
+func ReadNpy(r io.Reader) (*Tensor, error) {
+	magic := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic[:len(npyMagic)], npyMagic) {
+		return nil, fmt.Errorf("invalid npy magic string %q", magic[:len(npyMagic)])
+	}
+
+	var headerLen uint32
+	switch major := magic[len(npyMagic)]; major {
+	case 1:
+		var l uint16
+		if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		headerLen = uint32(l)
+	case 2, 3:
+		if err := binary.Read(r, binary.LittleEndian, &headerLen); err != nil {
+			return nil, err
+		}
+		if headerLen > npyMaxHeaderLen {
+			return nil, fmt.Errorf("npy header too long (%d bytes)", headerLen)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported npy format version %d", major)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	byteOrder, dt, fortranOrder, shape, err := parseNpyHeader(string(header))
+	if err != nil {
+		return nil, err
+	}
+
+	elemSize := int(typeOf(dt, nil).Size())
+	size, err := npyDataSize(shape, elemSize)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readNpyData(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if byteOrder != nativeEndian {
+		swapBytes(data, elemSize)
+	}
+
+	if fortranOrder && len(shape) > 1 {
+		data = fortranToCOrder(data, shape, elemSize)
+	}
+
+	return newTensorFromBytes(data, shape, dt)
+}
+
+// WriteNpy writes a tensor as NumPy .npy data
+func WriteNpy(w io.Writer, t *Tensor) error {
+	dt := t.DType()
+
+	var descr string
+	for _, typ := range npyTypes {
+		if typ.dataType == dt {
+			descr = fmt.Sprintf("%c%c%d", npyByteOrderChar(typ.size), typ.kind, typ.size)
+			break
+		}
+	}
+	if descr == "" {
+		return fmt.Errorf("unsupported DType %d for npy", int(dt))
+	}
+
+	data, err := t.rawData()
+	if err != nil {
+		return err
+	}
+
+	shape := t.Shape()
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		dims[i] = strconv.FormatInt(d, 10)
+	}
+	shapeStr := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeStr += ","
+	}
+
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, shapeStr)
+
+	// Header is padded with spaces so that the data is 64 byte aligned
+	preambleLen := len(npyMagic) + 2 + 2
+	padding := 64 - (preambleLen+len(header)+1)%64
+	header += strings.Repeat(" ", padding%64) + "\n"
+
+	if len(header) > 0xffff {
+		return fmt.Errorf("npy header too long (%d bytes)", len(header))
+	}
+
+	buf := bufio.NewWriter(w)
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	buf.Write(data)
+
+	return buf.Flush()
+}
+
+// LoadNpy loads a tensor from a NumPy .npy file
+func LoadNpy(path string) (*Tensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadNpy(bufio.NewReader(f))
+}
+
+// SaveNpy saves a tensor to a NumPy .npy file
+func SaveNpy(path string, t *Tensor) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteNpy(f, t); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadNpz loads all arrays from a NumPy .npz archive (compressed or not)
+func LoadNpz(path string) (map[string]*Tensor, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	tensors := make(map[string]*Tensor, len(archive.File))
+	for _, file := range archive.File {
+		name := strings.TrimSuffix(file.Name, ".npy")
+
+		r, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := ReadNpy(bufio.NewReader(r))
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read array %s: %w", name, err)
+		}
+
+		tensors[name] = t
+	}
+
+	return tensors, nil
+}
+
+// SaveNpz saves tensors to an uncompressed NumPy .npz archive (like numpy.savez)
+func SaveNpz(path string, tensors map[string]*Tensor) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:   name + ".npy",
+			Method: zip.Store,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := WriteNpy(w, tensors[name]); err != nil {
+			return fmt.Errorf("unable to write array %s: %w", name, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func parseNpyHeader(header string) (byteOrder binary.ByteOrder, dt DType, fortranOrder bool, shape []int64, err error) {
+	descr := npyDescrRegexp.FindStringSubmatch(header)
+	if descr == nil {
+		return nil, dt, false, nil, fmt.Errorf("npy header is missing descr: %s", header)
+	}
+
+	byteOrder, dt, err = parseNpyDescr(descr[1])
+	if err != nil {
+		return nil, dt, false, nil, err
+	}
+
+	fortran := npyFortranOrderRegexp.FindStringSubmatch(header)
+	if fortran == nil {
+		return nil, dt, false, nil, fmt.Errorf("npy header is missing fortran_order: %s", header)
+	}
+	fortranOrder = fortran[1] == "True"
+
+	shapeMatch := npyShapeRegexp.FindStringSubmatch(header)
+	if shapeMatch == nil {
+		return nil, dt, false, nil, fmt.Errorf("npy header is missing shape: %s", header)
+	}
+
+	shape = []int64{}
+	for _, dim := range strings.Split(shapeMatch[1], ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		d, err := strconv.ParseInt(strings.TrimSuffix(dim, "L"), 10, 64)
+		if err != nil {
+			return nil, dt, false, nil, fmt.Errorf("invalid npy shape (%s): %w", shapeMatch[1], err)
+		}
+		if d < 0 {
+			return nil, dt, false, nil, fmt.Errorf("invalid npy shape (%s): negative dimension", shapeMatch[1])
+		}
+		shape = append(shape, d)
+	}
+
+	return byteOrder, dt, fortranOrder, shape, nil
+}
+
+// npyDataSize returns the size in bytes of data of given shape
+func npyDataSize(shape []int64, elemSize int) (int, error) {
+	for _, d := range shape {
+		if d == 0 {
+			return 0, nil
+		}
+	}
+
+	size := int64(elemSize)
+	for _, d := range shape {
+		if size > math.MaxInt64/d {
+			return 0, fmt.Errorf("npy shape %v is too large", shape)
+		}
+		size *= d
+	}
+	if int64(int(size)) != size {
+		return 0, fmt.Errorf("npy shape %v is too large", shape)
+	}
+
+	return int(size), nil
+}
+
+// readNpyData reads size bytes of data growing the buffer as data is read
+func readNpyData(r io.Reader, size int) ([]byte, error) {
+	capacity := size
+	if capacity > npyReadChunkSize {
+		capacity = npyReadChunkSize
+	}
+
+	data := make([]byte, 0, capacity)
+	for len(data) < size {
+		if len(data) == cap(data) {
+			capacity = 2 * cap(data)
+			if capacity > size || capacity < 0 {
+				capacity = size
+			}
+			grown := make([]byte, len(data), capacity)
+			copy(grown, data)
+			data = grown
+		}
+
+		n, err := io.ReadFull(r, data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func parseNpyDescr(descr string) (binary.ByteOrder, DType, error) {
+	if len(descr) < 3 {
+		return nil, 0, fmt.Errorf("unsupported npy descr %q", descr)
+	}
+
+	var byteOrder binary.ByteOrder
+	switch descr[0] {
+	case '<':
+		byteOrder = binary.LittleEndian
+	case '>':
+		byteOrder = binary.BigEndian
+	case '|', '=':
+		byteOrder = nativeEndian
+	default:
+		return nil, 0, fmt.Errorf("unsupported npy descr %q", descr)
+	}
+
+	size, err := strconv.Atoi(descr[2:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("unsupported npy descr %q", descr)
+	}
+
+	for _, typ := range npyTypes {
+		if typ.kind == descr[1] && typ.size == size {
+			return byteOrder, typ.dataType, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("unsupported npy descr %q", descr)
+}
+
+func npyByteOrderChar(size int) byte {
+	if size == 1 {
+		return '|'
+	}
+	if nativeEndian == binary.BigEndian {
+		return '>'
+	}
+	return '<'
+}
+
+func swapBytes(data []byte, elemSize int) {
+	for i := 0; i+elemSize <= len(data); i += elemSize {
+		elem := data[i : i+elemSize]
+		for j := 0; j < elemSize/2; j++ {
+			elem[j], elem[elemSize-1-j] = elem[elemSize-1-j], elem[j]
+		}
+	}
+}
+
+// fortranToCOrder reorders column-major data into row-major order
+func fortranToCOrder(data []byte, shape []int64, elemSize int) []byte {
+	res := make([]byte, len(data))
+	n := numElements(shape)
+	index := make([]int64, len(shape))
+
+	for i := int64(0); i < n; i++ {
+		// index is the multi-dimensional index of element i in C order
+		offset := int64(0)
+		stride := int64(1)
+		for d := 0; d < len(shape); d++ {
+			offset += index[d] * stride
+			stride *= shape[d]
+		}
+
+		copy(res[i*int64(elemSize):(i+1)*int64(elemSize)], data[offset*int64(elemSize):(offset+1)*int64(elemSize)])
+
+		for d := len(shape) - 1; d >= 0; d-- {
+			index[d]++
+			if index[d] < shape[d] {
+				break
+			}
+			index[d] = 0
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,150 @@
+package torch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func Test_NpyRoundTrip(t *testing.T) {
+	values := []interface{}{
+		[][]uint8{{1, 2, 3}, {4, 5, 6}},
+		[][]int8{{-1, 2, 3}, {4, 5, 6}},
+		[][]int32{{-1, 2, 3}, {4, 5, 6}},
+		[][]int64{{-1, 2, 3}, {4, 5, 6}},
+		[][]float32{{-1.5, 2, 3}, {4, 5, 6}},
+		[][]float64{{-1.5, 2, 3}, {4, 5, 6}},
+		[]float32{1, 2},
+		float64(3),
+	}
+
+	for _, value := range values {
+		tensor, err := NewTensor(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := &bytes.Buffer{}
+		if err := WriteNpy(buf, tensor); err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := tensor.rawData()
+		if (buf.Len()-len(data))%64 != 0 {
+			t.Errorf("%T: data should be 64 byte aligned", value)
+		}
+
+		res, err := ReadNpy(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.DType() != tensor.DType() {
+			t.Errorf("%T: wrong dtype %v", value, res.DType())
+		}
+
+		if !reflect.DeepEqual(res.Value(), value) {
+			t.Errorf("%T: wrong value %v", value, res.Value())
+		}
+	}
+}
+
+func Test_ReadNpyFortranOrderBigEndian(t *testing.T) {
+	header := "{'descr': '>i4', 'fortran_order': True, 'shape': (2, 3), }\n"
+
+	buf := &bytes.Buffer{}
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	// column-major data of [[1, 2, 3], [4, 5, 6]]
+	binary.Write(buf, binary.BigEndian, []int32{1, 4, 2, 5, 3, 6})
+
+	tensor, err := ReadNpy(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]int32{{1, 2, 3}, {4, 5, 6}}
+	if !reflect.DeepEqual(tensor.Value(), expected) {
+		t.Error("wrong value", tensor.Value())
+	}
+}
+
+func Test_ReadNpyUnsupportedType(t *testing.T) {
+	header := "{'descr': '<c16', 'fortran_order': False, 'shape': (1,), }\n"
+
+	buf := &bytes.Buffer{}
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+
+	if _, err := ReadNpy(buf); err == nil {
+		t.Error("should return an error")
+	}
+}
+
+func Test_ReadNpyInvalidShape(t *testing.T) {
+	shapes := []string{"(-1,)", "(4611686018427387904, 4)", "(1099511627776,)"}
+
+	for _, shape := range shapes {
+		header := "{'descr': '<f4', 'fortran_order': False, 'shape': " + shape + ", }\n"
+
+		buf := &bytes.Buffer{}
+		buf.Write(npyMagic)
+		buf.Write([]byte{1, 0})
+		binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+		buf.WriteString(header)
+		binary.Write(buf, binary.LittleEndian, []float32{1, 2})
+
+		if _, err := ReadNpy(buf); err == nil {
+			t.Errorf("should return an error for shape %s", shape)
+		}
+	}
+}
+
+func Test_ReadNpyHeaderTooLong(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.Write(npyMagic)
+	buf.Write([]byte{2, 0})
+	binary.Write(buf, binary.LittleEndian, uint32(0xffffffff))
+
+	if _, err := ReadNpy(buf); err == nil {
+		t.Error("should return an error")
+	}
+}
+
+func Test_SaveAndLoadNpz(t *testing.T) {
+	dir, err := ioutil.TempDir("", "npz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	a, _ := NewTensor([]float32{1, 2})
+	b, _ := NewTensor([][]int64{{3}, {4}})
+
+	file := path.Join(dir, "arrays.npz")
+	if err := SaveNpz(file, map[string]*Tensor{"a": a, "b": b}); err != nil {
+		t.Fatal(err)
+	}
+
+	tensors, err := LoadNpz(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(tensors["a"].Value(), []float32{1, 2}) {
+		t.Error("wrong value for a", tensors["a"].Value())
+	}
+
+	if !reflect.DeepEqual(tensors["b"].Value(), [][]int64{{3}, {4}}) {
+		t.Error("wrong value for b", tensors["b"].Value())
+	}
+}
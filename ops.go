@@ -0,0 +1,51 @@
+package torch
+
+// #include "torch.hpp"
+// #include <stdlib.h>
+import "C"
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+// Stack concatenates tensors of the same shape along a new dimension
+func Stack(tensors []*Tensor, dim int64) (*Tensor, error) {
+	if len(tensors) == 0 {
+		return nil, errors.New("stack expects a non-empty list of tensors")
+	}
+
+	contexts := tensorContexts(tensors)
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_Stack(tensorContextsPtr(contexts), C.ulong(len(contexts)), C.int64_t(dim), &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	runtime.KeepAlive(tensors)
+
+	return tensorWithContext(ctx), nil
+}
+
+// Unbind removes a dimension of a tensor returning all slices along it
+func Unbind(t *Tensor, dim int64) ([]*Tensor, error) {
+	var resSize C.ulong
+	var cErr C.Torch_Error
+	resPtr := C.Torch_Unbind(t.context, C.int64_t(dim), &resSize, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(resPtr))
+
+	resSlice := (*[1 << 30]C.Torch_TensorContext)(unsafe.Pointer(resPtr))[:resSize:resSize]
+
+	tensors := make([]*Tensor, len(resSlice))
+	for i, ctx := range resSlice {
+		tensors[i] = tensorWithContext(ctx)
+	}
+
+	runtime.KeepAlive(t)
+
+	return tensors, nil
+}
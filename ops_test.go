@@ -0,0 +1,46 @@
+package torch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Stack(t *testing.T) {
+	a, _ := NewTensor([]float32{1, 2})
+	b, _ := NewTensor([]float32{3, 4})
+
+	res, err := Stack([]*Tensor{a, b}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res.Value(), [][]float32{{1, 2}, {3, 4}}) {
+		t.Error("wrong value", res.Value())
+	}
+
+	c, _ := NewTensor([]float32{5})
+	if _, err := Stack([]*Tensor{a, c}, 0); err == nil {
+		t.Error("should return an error for tensors of different shape")
+	}
+}
+
+func Test_Unbind(t *testing.T) {
+	tensor, _ := NewTensor([][]float32{{1, 2}, {3, 4}, {5, 6}})
+
+	res, err := Unbind(tensor, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 3 {
+		t.Fatal("wrong number of tensors", len(res))
+	}
+
+	if !reflect.DeepEqual(res[2].Value(), []float32{5, 6}) {
+		t.Error("wrong value", res[2].Value())
+	}
+
+	if _, err := Unbind(tensor, 2); err == nil {
+		t.Error("should return an error for invalid dimension")
+	}
+}
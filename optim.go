@@ -0,0 +1,284 @@
+package torch
+
+// #include "torch.hpp"
+// #include <stdlib.h>
+import "C"
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"unsafe"
+)
+
+// Optimizer updates tensors (parameters) based on their gradients using LibTorch's optimizer implementations
+type Optimizer struct {
+	context C.Torch_OptimizerContext
+	params  []*Tensor
+}
+
+// SGDOptions options for the stochastic gradient descent optimizer
+type SGDOptions struct {
+	LearningRate float64
+	Momentum     float64
+	Dampening    float64
+	WeightDecay  float64
+	Nesterov     bool
+}
+
+// AdamOptions options for the Adam optimizer. Zero values of Beta1, Beta2 and Eps are replaced with PyTorch defaults (0.9, 0.999 and 1e-8)
+type AdamOptions struct {
+	LearningRate float64
+	Beta1        float64
+	Beta2        float64
+	WeightDecay  float64
+	Eps          float64
+	AMSGrad      bool
+}
+
+// NewSGD returns a stochastic gradient descent optimizer for given parameters
+func NewSGD(params []*Tensor, opts SGDOptions) (*Optimizer, error) {
+	contexts := tensorContexts(params)
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_SGD(
+		tensorContextsPtr(contexts),
+		C.ulong(len(contexts)),
+		C.double(opts.LearningRate),
+		C.double(opts.Momentum),
+		C.double(opts.Dampening),
+		C.double(opts.WeightDecay),
+		cBool(opts.Nesterov),
+		&cErr,
+	)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return optimizerWithContext(ctx, params), nil
+}
+
+// NewAdam returns an Adam optimizer for given parameters
+func NewAdam(params []*Tensor, opts AdamOptions) (*Optimizer, error) {
+	if opts.Beta1 == 0 {
+		opts.Beta1 = 0.9
+	}
+	if opts.Beta2 == 0 {
+		opts.Beta2 = 0.999
+	}
+	if opts.Eps == 0 {
+		opts.Eps = 1e-8
+	}
+
+	contexts := tensorContexts(params)
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_Adam(
+		tensorContextsPtr(contexts),
+		C.ulong(len(contexts)),
+		C.double(opts.LearningRate),
+		C.double(opts.Beta1),
+		C.double(opts.Beta2),
+		C.double(opts.WeightDecay),
+		C.double(opts.Eps),
+		cBool(opts.AMSGrad),
+		&cErr,
+	)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return optimizerWithContext(ctx, params), nil
+}
+
+func optimizerWithContext(ctx C.Torch_OptimizerContext, params []*Tensor) *Optimizer {
+	opt := &Optimizer{context: ctx, params: params}
+	runtime.SetFinalizer(opt, (*Optimizer).finalize)
+	return opt
+}
+
+// Parameters returns the tensors updated by the optimizer
+func (o *Optimizer) Parameters() []*Tensor {
+	return o.params
+}
+
+// Step performs a single optimization step
+func (o *Optimizer) Step() error {
+	var cErr C.Torch_Error
+	C.Torch_OptimizerStep(o.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ZeroGrad resets the gradients of all parameters
+func (o *Optimizer) ZeroGrad() error {
+	var cErr C.Torch_Error
+	C.Torch_OptimizerZeroGrad(o.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LearningRate returns the current learning rate. It panics if LibTorch fails (see LearningRateE).
+func (o *Optimizer) LearningRate() float64 {
+	lr, err := o.LearningRateE()
+	if err != nil {
+		panic(err)
+	}
+	return lr
+}
+
+// LearningRateE returns the current learning rate
+func (o *Optimizer) LearningRateE() (float64, error) {
+	var cErr C.Torch_Error
+	lr := C.Torch_OptimizerLearningRate(o.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return 0, err
+	}
+
+	return float64(lr), nil
+}
+
+// SetLearningRate sets the learning rate used by following steps
+func (o *Optimizer) SetLearningRate(lr float64) error {
+	var cErr C.Torch_Error
+	C.Torch_OptimizerSetLearningRate(o.context, C.double(lr), &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Save saves optimizer state (and the state of given schedulers) to given path
+func (o *Optimizer) Save(path string, schedulers ...LRScheduler) error {
+	lr, err := o.LearningRateE()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{optimizerLearningRateKey}
+	values := []float64{lr}
+	for i, s := range schedulers {
+		state := s.StateDict()
+		for _, key := range sortedKeys(state) {
+			keys = append(keys, schedulerStateKey(i, key))
+			values = append(values, state[key])
+		}
+	}
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	ckeys := cStrings(keys)
+	defer freeCStrings(ckeys)
+
+	var cErr C.Torch_Error
+	C.Torch_OptimizerSave(o.context, cpath, cStringsPtr(ckeys), float64sPtr(values), C.ulong(len(keys)), &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Load restores optimizer state (and the state of given schedulers) from given path.
+// Schedulers must be passed in the same order they were given to Save.
+func (o *Optimizer) Load(path string, schedulers ...LRScheduler) error {
+	keys := []string{optimizerLearningRateKey}
+	for i, s := range schedulers {
+		for _, key := range sortedKeys(s.StateDict()) {
+			keys = append(keys, schedulerStateKey(i, key))
+		}
+	}
+
+	values := make([]float64, len(keys))
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	ckeys := cStrings(keys)
+	defer freeCStrings(ckeys)
+
+	var cErr C.Torch_Error
+	C.Torch_OptimizerLoad(o.context, cpath, cStringsPtr(ckeys), float64sPtr(values), C.ulong(len(keys)), &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	if err := o.SetLearningRate(values[0]); err != nil {
+		return err
+	}
+
+	offset := 1
+	for _, s := range schedulers {
+		state := s.StateDict()
+		for _, key := range sortedKeys(state) {
+			state[key] = values[offset]
+			offset++
+		}
+		if err := s.LoadStateDict(state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *Optimizer) finalize() {
+	C.Torch_DeleteOptimizer(o.context)
+}
+
+const optimizerLearningRateKey = "optimizer.learning_rate"
+
+func schedulerStateKey(index int, key string) string {
+	return "scheduler." + strconv.Itoa(index) + "." + key
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func tensorContextsPtr(contexts []C.Torch_TensorContext) *C.Torch_TensorContext {
+	if len(contexts) == 0 {
+		return nil
+	}
+	return (*C.Torch_TensorContext)(&contexts[0])
+}
+
+func cStrings(strs []string) []*C.char {
+	cstrs := make([]*C.char, len(strs))
+	for i, s := range strs {
+		cstrs[i] = C.CString(s)
+	}
+	return cstrs
+}
+
+func cStringsPtr(cstrs []*C.char) **C.char {
+	if len(cstrs) == 0 {
+		return nil
+	}
+	return (**C.char)(&cstrs[0])
+}
+
+func freeCStrings(cstrs []*C.char) {
+	for _, s := range cstrs {
+		C.free(unsafe.Pointer(s))
+	}
+}
+
+func float64sPtr(values []float64) *C.double {
+	if len(values) == 0 {
+		return nil
+	}
+	return (*C.double)(unsafe.Pointer(&values[0]))
+}
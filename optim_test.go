@@ -0,0 +1,223 @@
+package torch
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+const squareScript = `
+def loss(w):
+	return (w * w).sum()
+`
+
+func newTestParameter(t *testing.T) *Tensor {
+	w, err := NewTensor([]float32{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetRequiresGrad(true); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func Test_SGDStep(t *testing.T) {
+	module, err := CompileTorchScript(squareScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestParameter(t)
+	opt, err := NewSGD([]*Tensor{w}, SGDOptions{LearningRate: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt.ZeroGrad()
+	loss, err := module.RunMethod("loss", w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loss.(*Tensor).Backward(); err != nil {
+		t.Fatal(err)
+	}
+
+	grad := w.Grad()
+	if grad == nil {
+		t.Fatal("gradient should have been computed")
+	}
+	if grad.Value().([]float32)[1] != 4 {
+		t.Error("d(w*w)/dw should equal 2w but got", grad.Value())
+	}
+
+	if err := opt.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	// w - lr * 2w
+	if val := w.Value().([]float32); math.Abs(float64(val[0])-0.8) > 1e-6 || math.Abs(float64(val[1])-1.6) > 1e-6 {
+		t.Error("wrong value after step", val)
+	}
+}
+
+func Test_LRSchedulers(t *testing.T) {
+	w := newTestParameter(t)
+	opt, err := NewAdam([]*Tensor{w}, AdamOptions{LearningRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stepLR, err := NewStepLR(opt, 2, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exponentialLR, err := NewExponentialLR(opt, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosineAnnealingLR, err := NewCosineAnnealingLR(opt, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linearWarmupLR, err := NewLinearWarmupLR(opt, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name      string
+		scheduler LRScheduler
+		expected  []float64
+	}{
+		{"StepLR", stepLR, []float64{1, 1, 0.5, 0.5, 0.25}},
+		{"ExponentialLR", exponentialLR, []float64{1, 0.5, 0.25, 0.125, 0.0625}},
+		{"CosineAnnealingLR", cosineAnnealingLR, []float64{1, 0.8535533, 0.5, 0.1464466, 0}},
+		{"LinearWarmupLR", linearWarmupLR, []float64{0, 0.25, 0.5, 0.75, 1}},
+	}
+
+	for _, step := range steps {
+		if err := opt.SetLearningRate(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := step.scheduler.LoadStateDict(map[string]float64{"base_lr": 1, "last_epoch": 0}); err != nil {
+			t.Fatal(err)
+		}
+		for i, expected := range step.expected {
+			if i > 0 {
+				if err := step.scheduler.Step(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if math.Abs(opt.LearningRate()-expected) > 1e-6 {
+				t.Errorf("%s: wrong learning rate at step %d: %v (expected %v)", step.name, i, opt.LearningRate(), expected)
+			}
+		}
+	}
+
+	oneCycle, err := NewOneCycleLR(opt, OneCycleLROptions{MaxLR: 1, TotalSteps: 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(oneCycle.LearningRate()-0.04) > 1e-6 {
+		t.Error("wrong initial learning rate", oneCycle.LearningRate())
+	}
+	for i := 0; i < 10; i++ {
+		if err := oneCycle.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if math.Abs(oneCycle.LearningRate()-0.000004) > 1e-9 {
+		t.Error("wrong final learning rate", oneCycle.LearningRate())
+	}
+}
+
+func Test_LRSchedulersInvalid(t *testing.T) {
+	w := newTestParameter(t)
+	opt, err := NewAdam([]*Tensor{w}, AdamOptions{LearningRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewStepLR(opt, 0, 0.5); err == nil {
+		t.Error("expected an error for a zero step size")
+	}
+	if _, err := NewCosineAnnealingLR(opt, 0, 0); err == nil {
+		t.Error("expected an error for a zero TMax")
+	}
+	for _, totalSteps := range []int{0, 1, 3} {
+		if _, err := NewOneCycleLR(opt, OneCycleLROptions{MaxLR: 1, TotalSteps: totalSteps}); err == nil {
+			t.Errorf("expected an error for %d total steps", totalSteps)
+		}
+	}
+	if _, err := NewOneCycleLR(opt, OneCycleLROptions{MaxLR: 1, TotalSteps: 100, PctStart: 1.5}); err == nil {
+		t.Error("expected an error for PctStart above 1")
+	}
+
+	scheduler, err := NewStepLR(opt, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.LoadStateDict(map[string]float64{"base_lr": 1}); err == nil {
+		t.Error("expected an error for a state without last_epoch")
+	}
+}
+
+func Test_SaveAndLoadOptimizer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "optimizers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	w := newTestParameter(t)
+	opt, err := NewSGD([]*Tensor{w}, SGDOptions{LearningRate: 1, Momentum: 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler, err := NewStepLR(opt, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := scheduler.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := opt.Save(path.Join(dir, "optimizer.pt"), scheduler); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewSGD([]*Tensor{w}, SGDOptions{LearningRate: 1, Momentum: 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restoredScheduler, err := NewStepLR(restored, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Load(path.Join(dir, "optimizer.pt"), restoredScheduler); err != nil {
+		t.Fatal(err)
+	}
+
+	if restoredScheduler.LastEpoch() != 2 {
+		t.Error("wrong epoch restored", restoredScheduler.LastEpoch())
+	}
+
+	if restored.LearningRate() != 0.25 {
+		t.Error("wrong learning rate restored", restored.LearningRate())
+	}
+
+	if err := restoredScheduler.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if restored.LearningRate() != 0.125 {
+		t.Error("wrong learning rate after step", restored.LearningRate())
+	}
+}
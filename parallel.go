@@ -0,0 +1,38 @@
+package torch
+
+// #include "torch.hpp"
+import "C"
+import "fmt"
+
+// LibTorch parallelizes single operations (intra-op) on a thread pool of GetNumThreads threads,
+// by default one per physical core. Every goroutine running a method concurrently uses the same
+// pool, so running N methods concurrently on top of the default pool oversubscribes the CPU N times.
+//
+// A good policy for servers handling many concurrent requests is to bound the number of concurrent
+// Run calls (e.g. with a Batcher, a ModulePool or a semaphore) to C and call
+// SetNumThreads(runtime.NumCPU() / C) once at startup, before running any method. Latency sensitive
+// services with little concurrency should keep the default. The settings are process wide; LibTorch
+// has no per-call thread count.
+//
+// LibTorch 1.0.1 has no inter-op thread pool setting (at::set_num_interop_threads was added in
+// LibTorch 1.2), so there is no SetNumInteropThreads. Concurrency between calls is controlled from Go.
+
+// SetNumThreads sets the number of threads used for intra-op parallelism
+func SetNumThreads(n int) error {
+	if n < 1 {
+		return fmt.Errorf("number of threads should be positive (got %d)", n)
+	}
+
+	var cErr C.Torch_Error
+	C.Torch_SetNumThreads(C.int(n), &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetNumThreads returns the number of threads used for intra-op parallelism
+func GetNumThreads() int {
+	return int(C.Torch_GetNumThreads())
+}
@@ -0,0 +1,668 @@
+package torch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Pickle opcodes used by torch.save (protocol 2) and by Python pickles in general
+const (
+	pickleMark            = '('
+	pickleStop            = '.'
+	pickleBinFloat        = 'G'
+	pickleBinInt          = 'J'
+	pickleBinInt1         = 'K'
+	pickleBinInt2         = 'M'
+	pickleNone            = 'N'
+	pickleBinPersID       = 'Q'
+	pickleReduce          = 'R'
+	pickleBinString       = 'T'
+	pickleShortBinString  = 'U'
+	pickleBinUnicode      = 'X'
+	pickleAppend          = 'a'
+	pickleBuild           = 'b'
+	pickleGlobal          = 'c'
+	pickleAppends         = 'e'
+	pickleBinGet          = 'h'
+	pickleLongBinGet      = 'j'
+	pickleEmptyList       = ']'
+	pickleBinPut          = 'q'
+	pickleLongBinPut      = 'r'
+	pickleSetItem         = 's'
+	pickleTuple           = 't'
+	pickleSetItems        = 'u'
+	pickleEmptyDict       = '}'
+	pickleEmptyTuple      = ')'
+	pickleBinBytes        = 'B'
+	pickleShortBinBytes   = 'C'
+	pickleProto           = '\x80'
+	pickleNewObj          = '\x81'
+	pickleTuple1          = '\x85'
+	pickleTuple2          = '\x86'
+	pickleTuple3          = '\x87'
+	pickleNewTrue         = '\x88'
+	pickleNewFalse        = '\x89'
+	pickleLong1           = '\x8a'
+	pickleShortBinUnicode = '\x8c'
+	pickleStackGlobal     = '\x93'
+	pickleMemoize         = '\x94'
+	pickleFrame           = '\x95'
+)
+
+// pickledTensor is a tensor as stored by torch.save: a view (offset, shape and strides in
+// elements) into the storage saved in the archive record data/<key>
+type pickledTensor struct {
+	dtype  DType
+	key    string
+	numel  int64
+	offset int64
+	shape  []int64
+	stride []int64
+}
+
+// pickleWriter writes the protocol 2 pickle of a dict of tensors as torch.save does
+type pickleWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *pickleWriter) op(op byte) {
+	w.buf.WriteByte(op)
+}
+
+func (w *pickleWriter) global(module, name string) {
+	w.buf.WriteByte(pickleGlobal)
+	w.buf.WriteString(module + "\n" + name + "\n")
+}
+
+func (w *pickleWriter) str(s string) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(s)))
+	w.buf.WriteByte(pickleBinUnicode)
+	w.buf.Write(size[:])
+	w.buf.WriteString(s)
+}
+
+func (w *pickleWriter) int(v int64) {
+	switch {
+	case v >= 0 && v < 1<<8:
+		w.buf.Write([]byte{pickleBinInt1, byte(v)})
+	case v >= 0 && v < 1<<16:
+		w.buf.Write([]byte{pickleBinInt2, byte(v), byte(v >> 8)})
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(int32(v)))
+		w.buf.WriteByte(pickleBinInt)
+		w.buf.Write(b[:])
+	default:
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		w.buf.Write([]byte{pickleLong1, 8})
+		w.buf.Write(b[:])
+	}
+}
+
+func (w *pickleWriter) ints(values []int64) {
+	w.op(pickleMark)
+	for _, v := range values {
+		w.int(v)
+	}
+	w.op(pickleTuple)
+}
+
+// orderedDict writes an empty collections.OrderedDict
+func (w *pickleWriter) orderedDict() {
+	w.global("collections", "OrderedDict")
+	w.op(pickleEmptyTuple)
+	w.op(pickleReduce)
+}
+
+// tensor writes torch._utils._rebuild_tensor_v2(storage, offset, size, stride, False, OrderedDict())
+// with the storage as a persistent id ('storage', torch.FloatStorage, key, 'cpu', numel)
+func (w *pickleWriter) tensor(t pickledTensor, storageType string) {
+	w.global("torch._utils", "_rebuild_tensor_v2")
+	w.op(pickleMark)
+
+	w.op(pickleMark)
+	w.str("storage")
+	w.global("torch", storageType)
+	w.str(t.key)
+	w.str("cpu")
+	w.int(t.numel)
+	w.op(pickleTuple)
+	w.op(pickleBinPersID)
+
+	w.int(t.offset)
+	w.ints(t.shape)
+	w.ints(t.stride)
+	w.op(pickleNewFalse)
+	w.orderedDict()
+
+	w.op(pickleTuple)
+	w.op(pickleReduce)
+}
+
+// marshalTorchPickle returns the pickle of a dict mapping names to tensors
+func marshalTorchPickle(names []string, tensors []pickledTensor) ([]byte, error) {
+	w := &pickleWriter{}
+	w.buf.Write([]byte{pickleProto, 2})
+	w.op(pickleEmptyDict)
+	if len(names) > 0 {
+		w.op(pickleMark)
+		for i, name := range names {
+			storageType, ok := torchStorageType(tensors[i].dtype)
+			if !ok {
+				return nil, newError(UnsupportedTypeError, "unsupported DType %s for tensor %s", tensors[i].dtype, name)
+			}
+			w.str(name)
+			w.tensor(tensors[i], storageType)
+		}
+		w.op(pickleSetItems)
+	}
+	w.op(pickleStop)
+
+	return w.buf.Bytes(), nil
+}
+
+// Values created while unpickling
+type (
+	pickleGlobalRef struct{ module, name string }
+	pickleMarker    struct{}
+	pickleDict      struct {
+		keys   []interface{}
+		values []interface{}
+	}
+	pickleStorage struct {
+		dtype DType
+		key   string
+		numel int64
+	}
+)
+
+func (d *pickleDict) set(key, value interface{}) {
+	d.keys = append(d.keys, key)
+	d.values = append(d.values, value)
+}
+
+// unpickler evaluates the subset of the pickle protocol used by torch.save. Only the globals needed
+// to rebuild dicts of tensors are resolved so that arbitrary code is never run.
+type unpickler struct {
+	data  []byte
+	pos   int
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+var errPickleTruncated = errors.New("pickle data is too short")
+
+// unmarshalTorchPickle returns the object pickled by torch.save
+func unmarshalTorchPickle(data []byte) (interface{}, error) {
+	u := &unpickler{data: data, memo: map[int]interface{}{}}
+	return u.run()
+}
+
+func (u *unpickler) read(n int) ([]byte, error) {
+	if n < 0 || n > len(u.data)-u.pos {
+		return nil, errPickleTruncated
+	}
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+	return b, nil
+}
+
+func (u *unpickler) readLine() (string, error) {
+	end := bytes.IndexByte(u.data[u.pos:], '\n')
+	if end < 0 {
+		return "", errPickleTruncated
+	}
+	line := string(u.data[u.pos : u.pos+end])
+	u.pos += end + 1
+	return line, nil
+}
+
+func (u *unpickler) readUint(n int) (uint64, error) {
+	b, err := u.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, nil
+}
+
+func (u *unpickler) push(v interface{}) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pop() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("pickle stack underflow")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	if _, ok := v.(pickleMarker); ok {
+		return nil, errors.New("unexpected pickle mark")
+	}
+	return v, nil
+}
+
+func (u *unpickler) top() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("pickle stack underflow")
+	}
+	return u.stack[len(u.stack)-1], nil
+}
+
+// popMark pops the items pushed after the last mark
+func (u *unpickler) popMark() ([]interface{}, error) {
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(pickleMarker); ok {
+			items := append([]interface{}{}, u.stack[i+1:]...)
+			u.stack = u.stack[:i]
+			return items, nil
+		}
+	}
+	return nil, errors.New("pickle mark not found")
+}
+
+func (u *unpickler) run() (interface{}, error) {
+	for {
+		if u.pos >= len(u.data) {
+			return nil, errPickleTruncated
+		}
+		op := u.data[u.pos]
+		u.pos++
+
+		if err := u.step(op); err != nil {
+			if err == errPickleStop {
+				return u.pop()
+			}
+			return nil, err
+		}
+	}
+}
+
+var errPickleStop = errors.New("pickle stop")
+
+func (u *unpickler) step(op byte) error {
+	switch op {
+	case pickleProto:
+		_, err := u.read(1)
+		return err
+	case pickleFrame:
+		_, err := u.read(8)
+		return err
+	case pickleStop:
+		return errPickleStop
+	case pickleMark:
+		u.push(pickleMarker{})
+	case pickleNone:
+		u.push(nil)
+	case pickleNewTrue:
+		u.push(true)
+	case pickleNewFalse:
+		u.push(false)
+	case pickleBinInt:
+		v, err := u.readUint(4)
+		if err != nil {
+			return err
+		}
+		u.push(int64(int32(v)))
+	case pickleBinInt1:
+		v, err := u.readUint(1)
+		if err != nil {
+			return err
+		}
+		u.push(int64(v))
+	case pickleBinInt2:
+		v, err := u.readUint(2)
+		if err != nil {
+			return err
+		}
+		u.push(int64(v))
+	case pickleLong1:
+		n, err := u.readUint(1)
+		if err != nil {
+			return err
+		}
+		if n > 8 {
+			return fmt.Errorf("pickled integer of %d bytes is too large", n)
+		}
+		v, err := u.readUint(int(n))
+		if err != nil {
+			return err
+		}
+		// Sign extend the little endian two's complement value
+		if n > 0 && n < 8 && v&(1<<(8*n-1)) != 0 {
+			v |= ^uint64(0) << (8 * n)
+		}
+		u.push(int64(v))
+	case pickleBinFloat:
+		b, err := u.read(8)
+		if err != nil {
+			return err
+		}
+		u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case pickleBinUnicode, pickleBinString, pickleBinBytes:
+		n, err := u.readUint(4)
+		if err != nil {
+			return err
+		}
+		b, err := u.read(int(n))
+		if err != nil {
+			return err
+		}
+		u.push(string(b))
+	case pickleShortBinUnicode, pickleShortBinString, pickleShortBinBytes:
+		n, err := u.readUint(1)
+		if err != nil {
+			return err
+		}
+		b, err := u.read(int(n))
+		if err != nil {
+			return err
+		}
+		u.push(string(b))
+	case pickleGlobal:
+		module, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		name, err := u.readLine()
+		if err != nil {
+			return err
+		}
+		u.push(pickleGlobalRef{module, name})
+	case pickleStackGlobal:
+		name, err := u.pop()
+		if err != nil {
+			return err
+		}
+		module, err := u.pop()
+		if err != nil {
+			return err
+		}
+		moduleStr, ok1 := module.(string)
+		nameStr, ok2 := name.(string)
+		if !ok1 || !ok2 {
+			return errors.New("invalid pickle global")
+		}
+		u.push(pickleGlobalRef{moduleStr, nameStr})
+	case pickleEmptyTuple:
+		u.push([]interface{}{})
+	case pickleEmptyList:
+		u.push([]interface{}{})
+	case pickleEmptyDict:
+		u.push(&pickleDict{})
+	case pickleTuple:
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		u.push(items)
+	case pickleTuple1, pickleTuple2, pickleTuple3:
+		n := int(op-pickleTuple1) + 1
+		items := make([]interface{}, n)
+		for i := n - 1; i >= 0; i-- {
+			v, err := u.pop()
+			if err != nil {
+				return err
+			}
+			items[i] = v
+		}
+		u.push(items)
+	case pickleAppend:
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		return u.appendItems([]interface{}{v})
+	case pickleAppends:
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		return u.appendItems(items)
+	case pickleSetItem:
+		value, err := u.pop()
+		if err != nil {
+			return err
+		}
+		key, err := u.pop()
+		if err != nil {
+			return err
+		}
+		return u.setItems([]interface{}{key, value})
+	case pickleSetItems:
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		return u.setItems(items)
+	case pickleBinPut, pickleLongBinPut:
+		size := 1
+		if op == pickleLongBinPut {
+			size = 4
+		}
+		index, err := u.readUint(size)
+		if err != nil {
+			return err
+		}
+		v, err := u.top()
+		if err != nil {
+			return err
+		}
+		u.memo[int(index)] = v
+	case pickleMemoize:
+		v, err := u.top()
+		if err != nil {
+			return err
+		}
+		u.memo[len(u.memo)] = v
+	case pickleBinGet, pickleLongBinGet:
+		size := 1
+		if op == pickleLongBinGet {
+			size = 4
+		}
+		index, err := u.readUint(size)
+		if err != nil {
+			return err
+		}
+		v, ok := u.memo[int(index)]
+		if !ok {
+			return fmt.Errorf("pickle memo %d is not defined", index)
+		}
+		u.push(v)
+	case pickleBinPersID:
+		pid, err := u.pop()
+		if err != nil {
+			return err
+		}
+		storage, err := persistentStorage(pid)
+		if err != nil {
+			return err
+		}
+		u.push(storage)
+	case pickleReduce, pickleNewObj:
+		args, err := u.pop()
+		if err != nil {
+			return err
+		}
+		callable, err := u.pop()
+		if err != nil {
+			return err
+		}
+		v, err := reducePickle(callable, args)
+		if err != nil {
+			return err
+		}
+		u.push(v)
+	case pickleBuild:
+		// State set on objects (e.g. the _metadata of state dicts) is not needed
+		_, err := u.pop()
+		return err
+	default:
+		return fmt.Errorf("unsupported pickle opcode 0x%02x", op)
+	}
+
+	return nil
+}
+
+func (u *unpickler) appendItems(items []interface{}) error {
+	v, err := u.pop()
+	if err != nil {
+		return err
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return errors.New("pickle append to a value which is not a list")
+	}
+	u.push(append(list, items...))
+	return nil
+}
+
+func (u *unpickler) setItems(items []interface{}) error {
+	if len(items)%2 != 0 {
+		return errors.New("odd number of pickled dict items")
+	}
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	dict, ok := v.(*pickleDict)
+	if !ok {
+		return errors.New("pickle set item on a value which is not a dict")
+	}
+	for i := 0; i < len(items); i += 2 {
+		dict.set(items[i], items[i+1])
+	}
+	return nil
+}
+
+// persistentStorage resolves the persistent id ('storage', torch.FloatStorage, key, location, numel)
+func persistentStorage(pid interface{}) (pickleStorage, error) {
+	fields, ok := pid.([]interface{})
+	if !ok || len(fields) != 5 || fields[0] != "storage" {
+		return pickleStorage{}, fmt.Errorf("unsupported persistent id %v", pid)
+	}
+
+	typ, ok := fields[1].(pickleGlobalRef)
+	if !ok || typ.module != "torch" {
+		return pickleStorage{}, fmt.Errorf("unsupported storage type %v", fields[1])
+	}
+	dtype, ok := torchStorageDType(typ.name)
+	if !ok {
+		return pickleStorage{}, newError(UnsupportedTypeError, "unsupported storage type torch.%s", typ.name)
+	}
+
+	key, ok1 := fields[2].(string)
+	numel, ok2 := fields[4].(int64)
+	if !ok1 || !ok2 || numel < 0 {
+		return pickleStorage{}, fmt.Errorf("invalid storage %v", pid)
+	}
+
+	return pickleStorage{dtype: dtype, key: key, numel: numel}, nil
+}
+
+// reducePickle calls one of the globals torch.save pickles state dicts with
+func reducePickle(callable, args interface{}) (interface{}, error) {
+	global, ok := callable.(pickleGlobalRef)
+	if !ok {
+		return nil, errors.New("pickle reduce of a value which is not a global")
+	}
+	argList, ok := args.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments for %s.%s", global.module, global.name)
+	}
+
+	switch global.module + "." + global.name {
+	case "collections.OrderedDict":
+		return &pickleDict{}, nil
+	case "torch._utils._rebuild_tensor_v2":
+		if len(argList) < 4 {
+			return nil, fmt.Errorf("invalid arguments for %s.%s", global.module, global.name)
+		}
+		storage, ok := argList[0].(pickleStorage)
+		offset, ok2 := argList[1].(int64)
+		shape, ok3 := pickledInts(argList[2])
+		stride, ok4 := pickledInts(argList[3])
+		if !ok || !ok2 || !ok3 || !ok4 || len(shape) != len(stride) {
+			return nil, fmt.Errorf("invalid arguments for %s.%s", global.module, global.name)
+		}
+		return &pickledTensor{
+			dtype:  storage.dtype,
+			key:    storage.key,
+			numel:  storage.numel,
+			offset: offset,
+			shape:  shape,
+			stride: stride,
+		}, nil
+	case "torch._utils._rebuild_parameter", "torch._utils._rebuild_parameter_with_state":
+		if len(argList) < 1 {
+			return nil, fmt.Errorf("invalid arguments for %s.%s", global.module, global.name)
+		}
+		if _, ok := argList[0].(*pickledTensor); !ok {
+			return nil, fmt.Errorf("invalid arguments for %s.%s", global.module, global.name)
+		}
+		return argList[0], nil
+	}
+
+	return nil, newError(UnsupportedTypeError, "unsupported pickled object %s.%s", global.module, global.name)
+}
+
+func pickledInts(v interface{}) ([]int64, bool) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	ints := make([]int64, len(items))
+	for i, item := range items {
+		if ints[i], ok = item.(int64); !ok {
+			return nil, false
+		}
+	}
+	return ints, true
+}
+
+// maxPickleDictDepth is the deepest nesting of dicts flattened by flattenPickledTensors (pickles
+// can also contain dicts which contain themselves)
+const maxPickleDictDepth = 100
+
+// flattenPickledTensors returns the tensors of a (possibly nested) pickled dict by dotted name
+func flattenPickledTensors(v interface{}, prefix string, depth int, tensors map[string]*pickledTensor) error {
+	if depth >= maxPickleDictDepth {
+		return fmt.Errorf("dicts are nested deeper than %d levels", maxPickleDictDepth)
+	}
+
+	dict, ok := v.(*pickleDict)
+	if !ok {
+		return fmt.Errorf("expected a dict of tensors but got %T", v)
+	}
+
+	for i, key := range dict.keys {
+		name, ok := key.(string)
+		if !ok {
+			return fmt.Errorf("dict key %v is not a string", key)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		switch value := dict.values[i].(type) {
+		case *pickledTensor:
+			tensors[name] = value
+		case *pickleDict:
+			if err := flattenPickledTensors(value, name, depth+1, tensors); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("value of %s is not a tensor", name)
+		}
+	}
+
+	return nil
+}
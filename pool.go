@@ -0,0 +1,98 @@
+package torch
+
+import (
+	"context"
+	"fmt"
+)
+
+// ModulePool holds clones of a JITModule and hands each of them to one goroutine at a time.
+// It is meant for modules which are not safe to run concurrently (see JITModule) and bounds the
+// number of concurrent calls to the size of the pool.
+type ModulePool struct {
+	modules chan *JITModule
+	size    int
+}
+
+// NewModulePool returns a pool of size modules: the given module and size - 1 clones of it
+func NewModulePool(module *JITModule, size int) (*ModulePool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size should be positive (got %d)", size)
+	}
+
+	p := &ModulePool{
+		modules: make(chan *JITModule, size),
+		size:    size,
+	}
+
+	p.modules <- module
+	for i := 1; i < size; i++ {
+		clone, err := module.Clone()
+		if err != nil {
+			return nil, err
+		}
+		p.modules <- clone
+	}
+
+	return p, nil
+}
+
+// Size returns the number of modules in the pool
+func (p *ModulePool) Size() int {
+	return p.size
+}
+
+// Get takes a module from the pool, waiting until one is available or ctx is done. The module must
+// be returned with Put.
+func (p *ModulePool) Get(ctx context.Context) (*JITModule, error) {
+	select {
+	case m := <-p.modules:
+		return m, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Put returns a module taken with Get to the pool
+func (p *ModulePool) Put(m *JITModule) {
+	p.modules <- m
+}
+
+// RunMethod runs a method on a module from the pool. If ctx is done while the method is running,
+// RunMethod returns ctx.Err() right away and the module is returned to the pool once the method
+// has finished.
+func (p *ModulePool) RunMethod(ctx context.Context, method string, inputs ...interface{}) (interface{}, error) {
+	m, err := p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		p.Put(m)
+		return nil, err
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		defer p.Put(m)
+
+		value, err := m.RunMethod(method, inputs...)
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Forward runs the forward method on a module from the pool (see RunMethod)
+func (p *ModulePool) Forward(ctx context.Context, inputs ...interface{}) (interface{}, error) {
+	return p.RunMethod(ctx, "forward", inputs...)
+}
@@ -0,0 +1,137 @@
+package torch
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const counterScript = `
+def forward(a):
+	return a * 2
+`
+
+func Test_ModulePool(t *testing.T) {
+	module, err := CompileTorchScript(counterScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := NewModulePool(module, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			input, _ := NewTensor([]float32{float32(i)})
+			res, err := pool.Forward(context.Background(), input)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(res.(*Tensor).Value(), []float32{float32(i) * 2}) {
+				t.Error("wrong result", res.(*Tensor).Value())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(pool.modules) != pool.Size() {
+		t.Error("all modules should be returned to the pool", len(pool.modules))
+	}
+}
+
+// Test_ModulePoolState changes the state of pooled modules from many goroutines (run with -race)
+func Test_ModulePoolState(t *testing.T) {
+	module := newParameterModule(t)
+
+	pool, err := NewModulePool(module, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			m, err := pool.Get(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer pool.Put(m)
+
+			scale, _ := NewTensor([]float32{float32(i)})
+			if err := m.SetParameter("scale", scale); err != nil {
+				t.Error(err)
+				return
+			}
+
+			stateDict, err := m.StateDict()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if val := stateDict["scale"].Value(); !reflect.DeepEqual(val, []float32{float32(i)}) {
+				t.Error("state should not be changed by other goroutines", val)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every module of the pool has its own parameters
+	modules := make([]*JITModule, pool.Size())
+	for i := range modules {
+		modules[i], _ = pool.Get(context.Background())
+
+		scale, _ := NewTensor([]float32{float32(i)})
+		if err := modules[i].SetParameter("scale", scale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, m := range modules {
+		stateDict, err := m.StateDict()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val := stateDict["scale"].Value(); !reflect.DeepEqual(val, []float32{float32(i)}) {
+			t.Error("pooled modules should not share parameters", i, val)
+		}
+		pool.Put(m)
+	}
+}
+
+func Test_ModulePoolGet(t *testing.T) {
+	module, _ := CompileTorchScript(counterScript)
+	pool, err := NewModulePool(module, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := pool.Get(ctx); err != context.Canceled {
+		t.Error("expected context.Canceled while the pool is empty", err)
+	}
+
+	pool.Put(m)
+
+	if _, err := NewModulePool(module, 0); err == nil {
+		t.Error("should return an error for an empty pool")
+	}
+}
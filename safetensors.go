@@ -0,0 +1,258 @@
+package torch
+
+// #include "torch.hpp"
+import "C"
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+const safetensorsMetadataKey = "__metadata__"
+
+type safetensorsEntry struct {
+	DType       string   `json:"dtype"`
+	Shape       []int64  `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// mappedFile is a memory mapped file shared by tensors created on top of it. The mapping is owned
+// by LibTorch: the loader holds a reference until it returns and every tensor created over the
+// mapping holds one in its storage, so the file stays mapped as long as any storage uses it.
+type mappedFile struct {
+	context C.Torch_MappedFileContext
+	data    []byte
+}
+
+func (m *mappedFile) release() {
+	C.Torch_DeleteMappedFile(m.context)
+}
+
+// LoadSafetensors loads tensors from a .safetensors file. The file is memory mapped and,
+// when dtype and alignment allow, tensors are created over the mapped bytes without copying.
+func LoadSafetensors(path string) (map[string]*Tensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.Size() < 8 {
+		return nil, fmt.Errorf("invalid safetensors file %s", path)
+	}
+
+	var mapping *mappedFile
+	var data []byte
+	if ptr, err := mapFile(f, int(stat.Size())); err == nil {
+		mapping = &mappedFile{
+			context: C.Torch_NewMappedFile(ptr, C.size_t(stat.Size())),
+			data:    cBytes(ptr, uintptr(stat.Size())),
+		}
+		defer mapping.release()
+
+		data = mapping.data
+	} else {
+		data, err = ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headerLen := binary.LittleEndian.Uint64(data[:8])
+	if headerLen > uint64(len(data)-8) {
+		return nil, fmt.Errorf("invalid safetensors header size %d", headerLen)
+	}
+
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data[8:8+headerLen], &header); err != nil {
+		return nil, fmt.Errorf("invalid safetensors header: %w", err)
+	}
+
+	buffer := data[8+headerLen:]
+
+	tensors := make(map[string]*Tensor, len(header))
+	for name, raw := range header {
+		if name == safetensorsMetadataKey {
+			continue
+		}
+
+		var entry safetensorsEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, fmt.Errorf("invalid safetensors entry %s: %w", name, err)
+		}
+
+		t, err := safetensorsTensor(entry, buffer, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tensor %s: %w", name, err)
+		}
+
+		tensors[name] = t
+	}
+
+	return tensors, nil
+}
+
+func safetensorsTensor(entry safetensorsEntry, buffer []byte, mapping *mappedFile) (*Tensor, error) {
+	dt, ok := safetensorsDType(entry.DType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported dtype %s", entry.DType)
+	}
+
+	begin, end := entry.DataOffsets[0], entry.DataOffsets[1]
+	if begin < 0 || end < begin || end > int64(len(buffer)) {
+		return nil, fmt.Errorf("invalid data offsets %v", entry.DataOffsets)
+	}
+
+	elemSize := int64(typeOf(dt, nil).Size())
+	if end-begin != elemSize*numElements(entry.Shape) {
+		return nil, fmt.Errorf("data size %d does not match shape %v", end-begin, entry.Shape)
+	}
+
+	shape := entry.Shape
+	if shape == nil {
+		shape = []int64{}
+	}
+
+	data := buffer[begin:end]
+
+	zeroCopy := mapping != nil &&
+		len(data) > 0 &&
+		nativeEndian == binary.LittleEndian &&
+		uintptr(unsafe.Pointer(&data[0]))%uintptr(elemSize) == 0
+
+	if !zeroCopy {
+		if nativeEndian != binary.LittleEndian {
+			data = append([]byte(nil), data...)
+			swapBytes(data, int(elemSize))
+		}
+		return newTensorFromBytes(data, shape, dt)
+	}
+
+	var shapePtr *C.int64_t
+	if len(shape) > 0 {
+		shapePtr = (*C.int64_t)(unsafe.Pointer(&shape[0]))
+	}
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_NewMappedTensor(mapping.context, unsafe.Pointer(&data[0]), shapePtr, C.int(len(shape)), C.Torch_DataType(dt), &cErr)
+	runtime.KeepAlive(shape)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	t := tensorWithContext(ctx)
+	t.mapped = true
+
+	return t, nil
+}
+
+// SaveSafetensors saves tensors (and optional string metadata) to a .safetensors file
+func SaveSafetensors(path string, tensors map[string]*Tensor, metadata map[string]string) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := make(map[string]interface{}, len(tensors)+1)
+	if len(metadata) > 0 {
+		header[safetensorsMetadataKey] = metadata
+	}
+
+	datas := make([][]byte, len(names))
+	offset := int64(0)
+	for i, name := range names {
+		t := tensors[name]
+
+		dtypeName, ok := safetensorsDTypeName(t.DType())
+		if !ok {
+			return fmt.Errorf("unsupported DType %d for tensor %s", int(t.DType()), name)
+		}
+
+		data, err := t.rawData()
+		if err != nil {
+			return err
+		}
+		if nativeEndian != binary.LittleEndian {
+			swapBytes(data, int(typeOf(t.DType(), nil).Size()))
+		}
+		datas[i] = data
+
+		header[name] = safetensorsEntry{
+			DType:       dtypeName,
+			Shape:       append([]int64{}, t.Shape()...),
+			DataOffsets: [2]int64{offset, offset + int64(len(data))},
+		}
+		offset += int64(len(data))
+	}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// Header is padded with spaces so that the data is 8 byte aligned
+	if rem := len(headerBytes) % 8; rem != 0 {
+		headerBytes = append(headerBytes, strings.Repeat(" ", 8-rem)...)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	binary.Write(w, binary.LittleEndian, uint64(len(headerBytes)))
+	w.Write(headerBytes)
+	for _, data := range datas {
+		w.Write(data)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadSafetensors loads tensors from a .safetensors file into module parameters with matching
+// names (see LoadStateDict)
+func (m *JITModule) LoadSafetensors(path string, strict bool) (IncompatibleKeys, error) {
+	tensors, err := LoadSafetensors(path)
+	if err != nil {
+		return IncompatibleKeys{}, err
+	}
+
+	return m.LoadStateDict(tensors, strict)
+}
+
+func safetensorsDType(name string) (DType, bool) {
+	for _, typ := range safetensorsTypes {
+		if typ.name == name {
+			return typ.dataType, true
+		}
+	}
+	return 0, false
+}
+
+func safetensorsDTypeName(dt DType) (string, bool) {
+	for _, typ := range safetensorsTypes {
+		if typ.dataType == dt {
+			return typ.name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,192 @@
+package torch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func Test_SaveAndLoadSafetensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	values := map[string]interface{}{
+		"byte":   []uint8{1, 2, 3},
+		"char":   []int8{-1, 2},
+		"int":    [][]int32{{1, 2}, {3, 4}},
+		"long":   []int64{5},
+		"float":  [][]float32{{1.5}, {2.5}},
+		"double": []float64{-1, 1},
+	}
+
+	tensors := map[string]*Tensor{}
+	for name, value := range values {
+		tensors[name], err = NewTensor(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := path.Join(dir, "model.safetensors")
+	if err := SaveSafetensors(file, tensors, map[string]string{"format": "pt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSafetensors(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != len(values) {
+		t.Fatal("wrong number of tensors loaded", len(loaded))
+	}
+
+	for name, value := range values {
+		if !reflect.DeepEqual(loaded[name].Value(), value) {
+			t.Errorf("%s: wrong value %v", name, loaded[name].Value())
+		}
+	}
+
+	// header is padded to 8 bytes and tensors are stored in name order so "byte" starts at offset 0
+	if !loaded["byte"].mapped {
+		t.Error("aligned tensors should be created over the mapped file")
+	}
+}
+
+func Test_SafetensorsMappingOutlivesTensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	weight, _ := NewTensor([][]int64{{1, 2}, {3, 4}})
+
+	file := path.Join(dir, "model.safetensors")
+	if err := SaveSafetensors(file, map[string]*Tensor{"weight": weight}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSafetensors(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded["weight"].mapped {
+		t.Fatal("weight should be created over the mapped file")
+	}
+
+	// Views share the storage of the mapped tensor which is freed by the garbage collector
+	rows, err := Unbind(loaded["weight"], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded = nil
+	runtime.GC()
+	runtime.GC()
+
+	if val := rows[1].Value(); !reflect.DeepEqual(val, []int64{3, 4}) {
+		t.Error("wrong value read from the mapped file", val)
+	}
+}
+
+func Test_LoadSafetensorsInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	file := path.Join(dir, "invalid.safetensors")
+	ioutil.WriteFile(file, []byte{0xff, 0xff, 0, 0, 0, 0, 0, 0, '{', '}'}, 0644)
+
+	if _, err := LoadSafetensors(file); err == nil {
+		t.Error("should return an error")
+	}
+}
+
+func Test_JITModuleLoadSafetensorsMissingParameter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	module, err := CompileTorchScript(sumScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weight, _ := NewTensor([]float32{1})
+	file := path.Join(dir, "weights.safetensors")
+	if err := SaveSafetensors(file, map[string]*Tensor{"weight": weight}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := module.LoadSafetensors(file, false)
+	if err != nil {
+		t.Error("unexpected keys should be ignored in non-strict mode", err)
+	}
+	if !reflect.DeepEqual(keys.Unexpected, []string{"weight"}) {
+		t.Error("unexpected keys should be returned", keys)
+	}
+
+	_, err = module.LoadSafetensors(file, true)
+	if err == nil {
+		t.Fatal("should return an error")
+	}
+
+	if dictErr, ok := err.(*StateDictError); !ok || len(dictErr.Unexpected) != 1 || dictErr.Unexpected[0] != "weight" {
+		t.Error("wrong error returned", err)
+	}
+}
+
+func Test_JITModuleLoadSafetensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safetensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	module := newParameterModule(t)
+
+	weight, _ := NewTensor([][]float32{{-1, -2}, {-3, -4}})
+	bias, _ := NewTensor([]float32{-5, -6})
+	scale, _ := NewTensor([]float32{-7})
+
+	file := path.Join(dir, "weights.safetensors")
+	err = SaveSafetensors(file, map[string]*Tensor{"fc.weight": weight, "fc.bias": bias, "scale": scale}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := module.LoadSafetensors(file, true); err != nil {
+		t.Fatal(err)
+	}
+
+	stateDict, err := module.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"fc.weight": [][]float32{{-1, -2}, {-3, -4}},
+		"fc.bias":   []float32{-5, -6},
+		"scale":     []float32{-7},
+	}
+	for name, value := range expected {
+		if !reflect.DeepEqual(stateDict[name].Value(), value) {
+			t.Errorf("%s: wrong value %v", name, stateDict[name].Value())
+		}
+	}
+}
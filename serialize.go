@@ -0,0 +1,376 @@
+package torch
+
+// #include "torch.hpp"
+// #include <stdlib.h>
+import "C"
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// torchSaveVersion is the archive format version written by torch.save since PyTorch 1.6
+const torchSaveVersion = "3\n"
+
+// torchSaveAlignment is the alignment of tensor data in archives (as in torch.save) so that
+// records can be memory mapped
+const torchSaveAlignment = 64
+
+// Save saves a dictionary of tensors to given path in the format of torch.save (a zip archive
+// containing a pickled dict and the tensor data). Saved files can be loaded with torch.load in
+// Python.
+func Save(path string, tensors map[string]*Tensor) error {
+	names := make([]string, 0, len(tensors))
+	for name, tensor := range tensors {
+		if tensor == nil {
+			return fmt.Errorf("tensor %q is nil", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pickled := make([]pickledTensor, len(names))
+	data := make([][]byte, len(names))
+	for i, name := range names {
+		t := tensors[name]
+
+		dt, err := t.DTypeE()
+		if err != nil {
+			return err
+		}
+		shape, err := t.ShapeE()
+		if err != nil {
+			return err
+		}
+		if data[i], err = t.rawData(); err != nil {
+			return fmt.Errorf("tensor %s: %w", name, err)
+		}
+
+		pickled[i] = pickledTensor{
+			dtype:  dt,
+			key:    strconv.Itoa(i),
+			numel:  numElements(shape),
+			shape:  shape,
+			stride: contiguousStrides(shape),
+		}
+	}
+
+	pkl, err := marshalTorchPickle(names, pickled)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := writeTorchArchive(f, torchArchiveName(path), pkl, data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// torchArchiveName returns the name of the directory records are stored in (as torch.save,
+// the file name without its extension)
+func torchArchiveName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if name == "" {
+		return "archive"
+	}
+	return name
+}
+
+// offsetWriter counts the bytes written to w
+type offsetWriter struct {
+	w      io.Writer
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+func writeTorchArchive(w io.Writer, name string, pkl []byte, data [][]byte) error {
+	ow := &offsetWriter{w: w}
+	zw := zip.NewWriter(ow)
+
+	byteOrder := "little"
+	if nativeEndian == binary.BigEndian {
+		byteOrder = "big"
+	}
+
+	records := []struct {
+		name string
+		data []byte
+	}{
+		{name + "/data.pkl", pkl},
+		{name + "/byteorder", []byte(byteOrder)},
+	}
+	for i := range data {
+		records = append(records, struct {
+			name string
+			data []byte
+		}{name + "/data/" + strconv.Itoa(i), data[i]})
+	}
+	records = append(records, struct {
+		name string
+		data []byte
+	}{name + "/version", []byte(torchSaveVersion)})
+
+	// descriptor is the size of the data descriptor of the previous record which is written when
+	// the next record is created
+	descriptor := int64(0)
+	for _, record := range records {
+		if err := zw.Flush(); err != nil {
+			return err
+		}
+
+		// Records are stored uncompressed and padded with an extra field so that the data starts
+		// at an aligned offset (local file headers are 30 bytes followed by the name)
+		headerEnd := ow.offset + descriptor + 30 + int64(len(record.name)) + 4
+		padding := (torchSaveAlignment - headerEnd%torchSaveAlignment) % torchSaveAlignment
+		extra := make([]byte, 4+padding)
+		copy(extra, "FB")
+		binary.LittleEndian.PutUint16(extra[2:], uint16(padding))
+
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   record.name,
+			Method: zip.Store,
+			Extra:  extra,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(record.data); err != nil {
+			return err
+		}
+
+		descriptor = 16
+		if int64(len(record.data)) >= math.MaxUint32 {
+			descriptor = 24
+		}
+	}
+
+	return zw.Close()
+}
+
+// contiguousStrides returns the strides of a tensor with given shape in C order
+func contiguousStrides(shape []int64) []int64 {
+	strides := make([]int64, len(shape))
+	stride := int64(1)
+	for i := len(shape) - 1; i >= 0; i-- {
+		strides[i] = stride
+		stride *= shape[i]
+	}
+	return strides
+}
+
+// Load loads a dictionary of tensors saved with Save or with torch.save in Python (PyTorch 1.6
+// or later). Nested dicts (e.g. {"model": state_dict}) are flattened with dotted names.
+func Load(path string) (map[string]*Tensor, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		if err == zip.ErrFormat {
+			return nil, errors.New("unsupported file format (files written by torch.save before PyTorch 1.6 are not supported)")
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	return readTorchArchive(&r.Reader)
+}
+
+func readTorchArchive(r *zip.Reader) (map[string]*Tensor, error) {
+	if len(r.File) == 0 {
+		return nil, errors.New("empty archive")
+	}
+
+	// All records are stored in a directory named after the saved file
+	prefix := r.File[0].Name
+	if i := strings.Index(prefix, "/"); i >= 0 {
+		prefix = prefix[:i+1]
+	} else {
+		prefix = ""
+	}
+
+	records := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		records[strings.TrimPrefix(f.Name, prefix)] = f
+	}
+
+	if f, ok := records["byteorder"]; ok {
+		byteOrder, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if native := nativeEndian == binary.LittleEndian; (string(byteOrder) == "little") != native {
+			return nil, fmt.Errorf("unsupported byte order %s", byteOrder)
+		}
+	}
+
+	f, ok := records["data.pkl"]
+	if !ok {
+		return nil, errors.New("archive does not contain data.pkl")
+	}
+	pkl, err := readZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := unmarshalTorchPickle(pkl)
+	if err != nil {
+		return nil, err
+	}
+
+	pickled := map[string]*pickledTensor{}
+	if err := flattenPickledTensors(obj, "", 0, pickled); err != nil {
+		return nil, err
+	}
+
+	storages := map[string][]byte{}
+	tensors := make(map[string]*Tensor, len(pickled))
+	for name, p := range pickled {
+		storage, ok := storages[p.key]
+		if !ok {
+			f, ok := records["data/"+p.key]
+			if !ok {
+				return nil, fmt.Errorf("archive does not contain storage %s of tensor %s", p.key, name)
+			}
+			if storage, err = readZipFile(f); err != nil {
+				return nil, err
+			}
+			storages[p.key] = storage
+		}
+
+		data, err := p.data(storage)
+		if err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", name, err)
+		}
+
+		if tensors[name], err = NewTensorFromBytes(data, p.shape, p.dtype); err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", name, err)
+		}
+	}
+
+	return tensors, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
+
+// data returns the elements of the tensor view in C order
+func (p *pickledTensor) data(storage []byte) ([]byte, error) {
+	size := int64(typeOf(p.dtype, nil).Size())
+	if p.numel < 0 || p.numel > int64(len(storage))/size {
+		return nil, fmt.Errorf("storage %s has %d bytes but %d elements", p.key, len(storage), p.numel)
+	}
+
+	numel, err := npyDataSize(p.shape, 1)
+	if err != nil {
+		return nil, err
+	}
+	if numel == 0 {
+		return []byte{}, nil
+	}
+
+	// The view must be within the storage
+	last := p.offset
+	for i, dim := range p.shape {
+		if p.stride[i] < 0 {
+			return nil, fmt.Errorf("negative stride %d", p.stride[i])
+		}
+		last += (dim - 1) * p.stride[i]
+	}
+	if p.offset < 0 || last >= p.numel {
+		return nil, fmt.Errorf("view of %d elements at offset %d does not fit in storage of %d elements", numel, p.offset, p.numel)
+	}
+
+	strides := contiguousStrides(p.shape)
+	contiguous := true
+	for i := range p.shape {
+		if p.shape[i] != 1 && p.stride[i] != strides[i] {
+			contiguous = false
+		}
+	}
+	if contiguous {
+		return storage[p.offset*size : (p.offset+int64(numel))*size], nil
+	}
+
+	data := make([]byte, int64(numel)*size)
+	for i := int64(0); i < int64(numel); i++ {
+		offset := p.offset
+		rest := i
+		for dim := len(p.shape) - 1; dim >= 0; dim-- {
+			offset += (rest % p.shape[dim]) * p.stride[dim]
+			rest /= p.shape[dim]
+		}
+		copy(data[i*size:(i+1)*size], storage[offset*size:(offset+1)*size])
+	}
+
+	return data, nil
+}
+
+// saveModuleArchive saves tensors as the parameters of a TorchScript module archive which can be
+// loaded with LoadJITModule. Dotted names (e.g. "layer1.weight") are saved as parameters of
+// submodules. It is used by tests to create modules with parameters.
+func saveModuleArchive(path string, tensors map[string]*Tensor) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	named := make([]C.Torch_NamedTensor, len(names))
+	for i, name := range names {
+		named[i] = C.Torch_NamedTensor{
+			name:   C.CString(name),
+			tensor: tensors[name].context,
+		}
+	}
+
+	defer func() {
+		for _, n := range named {
+			C.free(unsafe.Pointer(n.name))
+		}
+	}()
+
+	var namedPtr *C.Torch_NamedTensor
+	if len(named) > 0 {
+		namedPtr = (*C.Torch_NamedTensor)(&named[0])
+	}
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cErr C.Torch_Error
+	C.Torch_SaveModuleArchive(cpath, namedPtr, C.ulong(len(named)), &cErr)
+	runtime.KeepAlive(tensors)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
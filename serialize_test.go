@@ -0,0 +1,142 @@
+package torch
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_SaveAndLoadTensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	embeddings, _ := NewTensor([][]float32{{1, 2}, {3, 4}})
+	ids, _ := NewTensor([]int64{7, 8})
+
+	err = Save(path.Join(dir, "tensors.pt"), map[string]*Tensor{
+		"embeddings": embeddings,
+		"ids":        ids,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path.Join(dir, "tensors.pt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != 2 {
+		t.Fatal("wrong number of tensors loaded", loaded)
+	}
+
+	if val := loaded["embeddings"].Value().([][]float32); val[1][0] != 3 {
+		t.Error("wrong value loaded", val)
+	}
+
+	if loaded["ids"].DType() != Long {
+		t.Error("wrong dtype loaded", loaded["ids"].DType())
+	}
+
+	if val := loaded["ids"].Value().([]int64); val[1] != 8 {
+		t.Error("wrong value loaded", val)
+	}
+}
+
+func Test_LoadMissingFile(t *testing.T) {
+	_, err := Load("this_file_wont_exist.pt")
+	if err == nil {
+		t.Error("should return an error")
+	}
+}
+
+func Test_SaveNilTensor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	err = Save(path.Join(dir, "tensors.pt"), map[string]*Tensor{"embeddings": nil})
+	if err == nil {
+		t.Error("should return an error for nil tensors")
+	}
+}
+
+func Test_SaveAndLoadNestedTensors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tensors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir) // clean up
+
+	weight, _ := NewTensor([]float32{1, 2})
+	bias, _ := NewTensor([]float32{3})
+	scale, _ := NewTensor([]float32{4})
+
+	err = Save(path.Join(dir, "tensors.pt"), map[string]*Tensor{
+		"layer1.fc.weight": weight,
+		"layer1.fc.bias":   bias,
+		"scale":            scale,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path.Join(dir, "tensors.pt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != 3 || loaded["layer1.fc.weight"] == nil || loaded["layer1.fc.bias"] == nil || loaded["scale"] == nil {
+		t.Fatal("wrong tensors loaded", loaded)
+	}
+
+	if val := loaded["layer1.fc.weight"].Value().([]float32); val[1] != 2 {
+		t.Error("wrong value loaded", val)
+	}
+}
+
+func Test_LoadTorchSave(t *testing.T) {
+	// testdata/torch_save.pt is generated by testdata/torch_save.py
+	loaded, err := Load(path.Join("testdata", "torch_save.pt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded) != 6 {
+		t.Fatal("wrong number of tensors loaded", loaded)
+	}
+
+	if val := loaded["model.fc.weight"].Value().([][]float32); val[1][2] != 5 {
+		t.Error("wrong value loaded", val)
+	}
+
+	// Views of the weight storage are loaded as contiguous tensors
+	if val := loaded["model.fc.weight_t"].Value().([][]float32); len(val) != 3 || val[2][0] != 2 || val[2][1] != 5 {
+		t.Error("wrong value loaded for transposed view", val)
+	}
+
+	if val := loaded["model.fc.row"].Value().([]float32); len(val) != 3 || val[0] != 3 {
+		t.Error("wrong value loaded for offset view", val)
+	}
+
+	if val := loaded["model.fc.bias"].Value().([]float64); val[1] != -1.5 {
+		t.Error("wrong value loaded", val)
+	}
+
+	if val := loaded["model.step"].Value().(int64); val != 3 {
+		t.Error("wrong value loaded", val)
+	}
+
+	if val := loaded["model.scale"].Value().([]float32); val[0] != 2 {
+		t.Error("wrong value loaded", val)
+	}
+}
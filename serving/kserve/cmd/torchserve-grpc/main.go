@@ -0,0 +1,77 @@
+// Command torchserve-grpc serves the forward methods of TorchScript models over gRPC using the
+// KServe v2 inference protocol (see package serving/kserve).
+//
+// Usage:
+//
+//	torchserve-grpc -addr :8081 -model resnet=resnet.pt -model bert=bert.pt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/orktes/go-torch"
+	"github.com/orktes/go-torch/serving/kserve"
+	"google.golang.org/grpc"
+)
+
+// modelFlags collects repeated -model name=path flags
+type modelFlags map[string]string
+
+func (f modelFlags) String() string {
+	models := make([]string, 0, len(f))
+	for name, path := range f {
+		models = append(models, name+"="+path)
+	}
+	return strings.Join(models, ",")
+}
+
+func (f modelFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("model should be given as name=path (got %q)", value)
+	}
+	if _, ok := f[parts[0]]; ok {
+		return fmt.Errorf("model %s given more than once", parts[0])
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+func main() {
+	models := modelFlags{}
+	addr := flag.String("addr", ":8081", "address to listen on")
+	flag.Var(models, "model", "TorchScript model to serve as name=path (can be repeated)")
+	flag.Parse()
+
+	if len(models) == 0 {
+		fmt.Fprintln(os.Stderr, "at least one -model is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	srv := kserve.NewServer()
+	for name, path := range models {
+		module, err := torch.LoadJITModule(path)
+		if err != nil {
+			log.Fatalf("unable to load model %s from %s: %v", name, path, err)
+		}
+		srv.AddModel(name, &kserve.Model{Module: module})
+		log.Printf("loaded model %s from %s", name, path)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g := grpc.NewServer()
+	srv.Register(g)
+
+	log.Printf("serving gRPC on %s", *addr)
+	log.Fatal(g.Serve(lis))
+}
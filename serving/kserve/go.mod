@@ -0,0 +1,18 @@
+module github.com/orktes/go-torch/serving/kserve
+
+go 1.23
+
+require (
+	github.com/orktes/go-torch v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.64.1
+	google.golang.org/protobuf v1.36.9
+)
+
+require (
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
+
+replace github.com/orktes/go-torch => ../..
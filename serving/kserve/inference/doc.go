@@ -0,0 +1,4 @@
+// Package inference contains the generated gRPC bindings for the KServe v2 (Triton compatible) inference protocol
+package inference
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grpc_predict_v2.proto
@@ -0,0 +1,331 @@
+// Package kserve serves JIT modules over gRPC using the KServe v2 (Triton compatible) inference protocol
+package kserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/orktes/go-torch"
+	"github.com/orktes/go-torch/serving"
+	"github.com/orktes/go-torch/serving/kserve/inference"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Platform is reported as the platform of every model in model metadata
+const Platform = "pytorch_torchscript"
+
+// Model is a JIT module method served under a name
+type Model struct {
+	// Module is the loaded TorchScript module
+	Module *torch.JITModule
+	// Method is the method run for inference requests (defaults to forward)
+	Method string
+	// Version is the model version reported in metadata and responses
+	Version string
+	// InputDType is the datatype reported for inputs in model metadata as TorchScript schemas do
+	// not contain tensor data types (defaults to torch.Float). Inference accepts any supported datatype.
+	InputDType torch.DType
+}
+
+// Server implements the KServe v2 GRPCInferenceService for JIT modules
+type Server struct {
+	inference.UnimplementedGRPCInferenceServiceServer
+
+	mutex  sync.RWMutex
+	models map[string]*Model
+}
+
+// NewServer returns a new Server without models
+func NewServer() *Server {
+	return &Server{models: map[string]*Model{}}
+}
+
+// Register registers the inference service on a gRPC server
+func (s *Server) Register(g *grpc.Server) {
+	inference.RegisterGRPCInferenceServiceServer(g, s)
+}
+
+// AddModel serves a model under given name (replacing a model with the same name)
+func (s *Server) AddModel(name string, model *Model) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.models[name] = model
+}
+
+// RemoveModel stops serving a model
+func (s *Server) RemoveModel(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.models, name)
+}
+
+// Models returns the names of served models
+func (s *Server) Models() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	names := make([]string, 0, len(s.models))
+	for name := range s.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *Server) model(name, version string) (*Model, *torch.JITModuleMethod, error) {
+	s.mutex.RLock()
+	model, ok := s.models[name]
+	s.mutex.RUnlock()
+
+	if !ok || (version != "" && version != model.Version) {
+		return nil, nil, status.Errorf(codes.NotFound, "model %s (version %q) not found", name, version)
+	}
+
+	method, err := model.Module.GetMethod(methodName(model))
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "model %s: %v", name, err)
+	}
+
+	return model, method, nil
+}
+
+// ServerLive returns true as long as the server responds
+func (s *Server) ServerLive(ctx context.Context, req *inference.ServerLiveRequest) (*inference.ServerLiveResponse, error) {
+	return &inference.ServerLiveResponse{Live: true}, nil
+}
+
+// ServerReady returns true when at least one model is served
+func (s *Server) ServerReady(ctx context.Context, req *inference.ServerReadyRequest) (*inference.ServerReadyResponse, error) {
+	return &inference.ServerReadyResponse{Ready: len(s.Models()) > 0}, nil
+}
+
+// ModelReady returns true if the model (and version if given) is served
+func (s *Server) ModelReady(ctx context.Context, req *inference.ModelReadyRequest) (*inference.ModelReadyResponse, error) {
+	_, _, err := s.model(req.GetName(), req.GetVersion())
+	return &inference.ModelReadyResponse{Ready: err == nil}, nil
+}
+
+// ServerMetadata returns the server name
+func (s *Server) ServerMetadata(ctx context.Context, req *inference.ServerMetadataRequest) (*inference.ServerMetadataResponse, error) {
+	return &inference.ServerMetadataResponse{Name: "go-torch"}, nil
+}
+
+// ModelMetadata describes model inputs and outputs derived from the method schema
+func (s *Server) ModelMetadata(ctx context.Context, req *inference.ModelMetadataRequest) (*inference.ModelMetadataResponse, error) {
+	model, method, err := s.model(req.GetName(), req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	inputDType := model.InputDType
+	if inputDType == 0 {
+		inputDType = torch.Float
+	}
+	inputDatatype, _ := Datatype(inputDType)
+
+	res := &inference.ModelMetadataResponse{
+		Name:     req.GetName(),
+		Platform: Platform,
+	}
+	if model.Version != "" {
+		res.Versions = []string{model.Version}
+	}
+
+	args, err := serving.MethodInputs(method)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	outputs, err := outputTypes(method)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	for _, arg := range args {
+		res.Inputs = append(res.Inputs, &inference.ModelMetadataResponse_TensorMetadata{
+			Name:     arg.Name,
+			Datatype: inputDatatype,
+			Shape:    []int64{-1},
+		})
+	}
+
+	for i := range outputs {
+		res.Outputs = append(res.Outputs, &inference.ModelMetadataResponse_TensorMetadata{
+			Name:  outputName(i),
+			Shape: []int64{-1},
+		})
+	}
+
+	return res, nil
+}
+
+// ModelInfer runs the model method for the request inputs. Inputs are matched to method arguments
+// by name (or input__<index>) and outputs are named output__<index> in the order the method returns them.
+func (s *Server) ModelInfer(ctx context.Context, req *inference.ModelInferRequest) (*inference.ModelInferResponse, error) {
+	model, method, err := s.model(req.GetModelName(), req.GetModelVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := serving.MethodInputs(method)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	inputs, err := decodeInputs(args, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	output, err := method.RunContext(ctx, inputs...)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, status.FromContextError(err).Err()
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	outputs := flattenOutput(output)
+
+	requested := map[string]bool{}
+	for _, out := range req.GetOutputs() {
+		requested[out.GetName()] = true
+	}
+
+	res := &inference.ModelInferResponse{
+		ModelName:    req.GetModelName(),
+		ModelVersion: model.Version,
+		Id:           req.GetId(),
+	}
+
+	for i, t := range outputs {
+		name := outputName(i)
+		if len(requested) > 0 && !requested[name] {
+			continue
+		}
+		delete(requested, name)
+
+		tensor, raw, err := encodeOutput(name, t)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		res.Outputs = append(res.Outputs, tensor)
+		res.RawOutputContents = append(res.RawOutputContents, raw)
+	}
+
+	for name := range requested {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown output %s", name)
+	}
+
+	return res, nil
+}
+
+func methodName(model *Model) string {
+	if model.Method == "" {
+		return "forward"
+	}
+	return model.Method
+}
+
+// outputTypes returns the types of method outputs with tuples flattened
+func outputTypes(method *torch.JITModuleMethod) ([]torch.Type, error) {
+	returns, err := method.ReturnsE()
+	if err != nil {
+		return nil, err
+	}
+
+	var types []torch.Type
+	for _, ret := range returns {
+		types = append(types, flattenType(ret.TypeInfo)...)
+	}
+	return types, nil
+}
+
+func flattenType(typ torch.Type) []torch.Type {
+	tuple, ok := typ.(torch.TupleType)
+	if !ok {
+		return []torch.Type{typ}
+	}
+
+	var types []torch.Type
+	for _, elem := range tuple.Elems {
+		types = append(types, flattenType(elem)...)
+	}
+	return types
+}
+
+// flattenOutput flattens (nested) tuples returned by a method into a list of tensors
+func flattenOutput(output interface{}) []*torch.Tensor {
+	switch v := output.(type) {
+	case *torch.Tensor:
+		return []*torch.Tensor{v}
+	case torch.Tuple:
+		var tensors []*torch.Tensor
+		for _, elem := range v {
+			tensors = append(tensors, flattenOutput(elem)...)
+		}
+		return tensors
+	default:
+		return nil
+	}
+}
+
+func inputName(i int) string {
+	return "input__" + strconv.Itoa(i)
+}
+
+func outputName(i int) string {
+	return "output__" + strconv.Itoa(i)
+}
+
+func decodeInputs(args []torch.JITModuleMethodArgument, req *inference.ModelInferRequest) ([]interface{}, error) {
+	if len(req.GetInputs()) != len(args) {
+		return nil, fmt.Errorf("expected %d inputs but got %d", len(args), len(req.GetInputs()))
+	}
+
+	raw := req.GetRawInputContents()
+	if len(raw) > 0 && len(raw) != len(req.GetInputs()) {
+		return nil, fmt.Errorf("expected raw contents for %d inputs but got %d", len(req.GetInputs()), len(raw))
+	}
+
+	inputs := make([]interface{}, len(args))
+	for i, input := range req.GetInputs() {
+		index := -1
+		for j, arg := range args {
+			if input.GetName() == arg.Name || input.GetName() == inputName(j) {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("unknown input %s", input.GetName())
+		}
+		if inputs[index] != nil {
+			return nil, fmt.Errorf("input %s given more than once", input.GetName())
+		}
+		if args[index].Type != "Tensor" {
+			return nil, fmt.Errorf("input %s of type %s is not supported", input.GetName(), args[index].Type)
+		}
+
+		var rawContents []byte
+		if len(raw) > 0 {
+			rawContents = raw[i]
+		}
+
+		t, err := decodeInput(input, rawContents)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input %s: %w", input.GetName(), err)
+		}
+		inputs[index] = t
+	}
+
+	return inputs, nil
+}
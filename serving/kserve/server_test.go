@@ -0,0 +1,208 @@
+package kserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/orktes/go-torch"
+	"github.com/orktes/go-torch/serving/kserve/inference"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const testScript = `
+def forward(a, b):
+	return (a + b, a - b)
+`
+
+func newTestClient(t *testing.T) (inference.GRPCInferenceServiceClient, func()) {
+	module, err := torch.CompileTorchScript(testScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer()
+	srv.AddModel("test", &Model{Module: module, Version: "1"})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := grpc.NewServer()
+	srv.Register(g)
+	go g.Serve(lis)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return inference.NewGRPCInferenceServiceClient(conn), func() {
+		conn.Close()
+		g.Stop()
+	}
+}
+
+func Test_ModelInfer(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, []float32{1, 2, 3, 4})
+
+	res, err := client.ModelInfer(context.Background(), &inference.ModelInferRequest{
+		ModelName: "test",
+		Id:        "req-1",
+		Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "FP32", Shape: []int64{2, 2}},
+			{Name: "input__1", Datatype: "FP32", Shape: []int64{2, 2}},
+		},
+		RawInputContents: [][]byte{raw.Bytes(), raw.Bytes()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.GetId() != "req-1" || res.GetModelVersion() != "1" || len(res.GetOutputs()) != 2 {
+		t.Fatal("wrong response", res)
+	}
+
+	out := res.GetOutputs()[0]
+	if out.GetName() != "output__0" || out.GetDatatype() != "FP32" || !reflect.DeepEqual(out.GetShape(), []int64{2, 2}) {
+		t.Error("wrong output", out)
+	}
+
+	sum := make([]float32, 4)
+	binary.Read(bytes.NewReader(res.GetRawOutputContents()[0]), binary.LittleEndian, sum)
+	if !reflect.DeepEqual(sum, []float32{2, 4, 6, 8}) {
+		t.Error("wrong output value", sum)
+	}
+}
+
+func Test_ModelInferTypedContents(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	contents := &inference.InferTensorContents{Int64Contents: []int64{5, 6}}
+	res, err := client.ModelInfer(context.Background(), &inference.ModelInferRequest{
+		ModelName: "test",
+		Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "INT64", Shape: []int64{2}, Contents: contents},
+			{Name: "b", Datatype: "INT64", Shape: []int64{2}, Contents: contents},
+		},
+		Outputs: []*inference.ModelInferRequest_InferRequestedOutputTensor{{Name: "output__1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.GetOutputs()) != 1 || res.GetOutputs()[0].GetName() != "output__1" || res.GetOutputs()[0].GetDatatype() != "INT64" {
+		t.Fatal("wrong outputs", res.GetOutputs())
+	}
+
+	diff := make([]int64, 2)
+	binary.Read(bytes.NewReader(res.GetRawOutputContents()[0]), binary.LittleEndian, diff)
+	if !reflect.DeepEqual(diff, []int64{0, 0}) {
+		t.Error("wrong output value", diff)
+	}
+}
+
+func Test_ModelInferErrors(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	contents := &inference.InferTensorContents{Fp32Contents: []float32{1, 2}}
+	tests := []struct {
+		req  *inference.ModelInferRequest
+		code codes.Code
+	}{
+		{&inference.ModelInferRequest{ModelName: "missing"}, codes.NotFound},
+		{&inference.ModelInferRequest{ModelName: "test", ModelVersion: "2"}, codes.NotFound},
+		{&inference.ModelInferRequest{ModelName: "test"}, codes.InvalidArgument},
+		{&inference.ModelInferRequest{ModelName: "test", Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "FP32", Shape: []int64{2}, Contents: contents},
+			{Name: "c", Datatype: "FP32", Shape: []int64{2}, Contents: contents},
+		}}, codes.InvalidArgument},
+		{&inference.ModelInferRequest{ModelName: "test", Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "FP32", Shape: []int64{3}, Contents: contents},
+			{Name: "b", Datatype: "FP32", Shape: []int64{2}, Contents: contents},
+		}}, codes.InvalidArgument},
+		{&inference.ModelInferRequest{ModelName: "test", Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "BYTES", Shape: []int64{2}, Contents: contents},
+			{Name: "b", Datatype: "FP32", Shape: []int64{2}, Contents: contents},
+		}}, codes.InvalidArgument},
+		// Shapes which do not match the contents must not be allocated
+		{&inference.ModelInferRequest{ModelName: "test", Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "FP32", Shape: []int64{1 << 40}, Contents: contents},
+			{Name: "b", Datatype: "FP32", Shape: []int64{2}, Contents: contents},
+		}}, codes.InvalidArgument},
+		{&inference.ModelInferRequest{ModelName: "test", Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "FP32", Shape: []int64{1 << 40, 1 << 40}, Contents: contents},
+			{Name: "b", Datatype: "FP32", Shape: []int64{2}, Contents: contents},
+		}}, codes.InvalidArgument},
+		{&inference.ModelInferRequest{ModelName: "test", Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "a", Datatype: "FP32", Shape: []int64{1 << 62}},
+			{Name: "b", Datatype: "FP32", Shape: []int64{1}},
+		}, RawInputContents: [][]byte{{0, 0, 0, 0}, {0, 0, 0, 0}}}, codes.InvalidArgument},
+	}
+
+	for i, test := range tests {
+		_, err := client.ModelInfer(context.Background(), test.req)
+		if status.Code(err) != test.code {
+			t.Error("wrong error for request", i, err)
+		}
+	}
+}
+
+func Test_ModelMetadataAndReady(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	metadata, err := client.ModelMetadata(context.Background(), &inference.ModelMetadataRequest{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.GetPlatform() != Platform || !reflect.DeepEqual(metadata.GetVersions(), []string{"1"}) {
+		t.Error("wrong metadata", metadata)
+	}
+	if len(metadata.GetInputs()) != 2 || metadata.GetInputs()[1].GetName() != "b" || metadata.GetInputs()[1].GetDatatype() != "FP32" {
+		t.Error("wrong inputs", metadata.GetInputs())
+	}
+	if len(metadata.GetOutputs()) != 2 || metadata.GetOutputs()[1].GetName() != "output__1" {
+		t.Error("wrong outputs", metadata.GetOutputs())
+	}
+
+	ready, err := client.ModelReady(context.Background(), &inference.ModelReadyRequest{Name: "test"})
+	if err != nil || !ready.GetReady() {
+		t.Error("model should be ready", err)
+	}
+
+	ready, err = client.ModelReady(context.Background(), &inference.ModelReadyRequest{Name: "missing"})
+	if err != nil || ready.GetReady() {
+		t.Error("missing model should not be ready", err)
+	}
+}
+
+func Test_Datatype(t *testing.T) {
+	for _, dt := range []torch.DType{torch.Byte, torch.Char, torch.Int, torch.Long, torch.Float, torch.Double} {
+		name, ok := Datatype(dt)
+		if !ok {
+			t.Error("no datatype for", dt)
+		}
+		if res, ok := DType(name); !ok || res != dt {
+			t.Error("wrong DType for", name, res)
+		}
+	}
+
+	if _, ok := DType("BYTES"); ok {
+		t.Error("BYTES should not be supported")
+	}
+}
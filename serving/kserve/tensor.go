@@ -0,0 +1,206 @@
+package kserve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/orktes/go-torch"
+	"github.com/orktes/go-torch/serving/kserve/inference"
+)
+
+var datatypes = []struct {
+	name     string
+	dataType torch.DType
+	typ      reflect.Type
+}{
+	{"UINT8", torch.Byte, reflect.TypeOf(uint8(0))},
+	{"INT8", torch.Char, reflect.TypeOf(int8(0))},
+	{"INT32", torch.Int, reflect.TypeOf(int32(0))},
+	{"INT64", torch.Long, reflect.TypeOf(int64(0))},
+	{"FP32", torch.Float, reflect.TypeOf(float32(0))},
+	{"FP64", torch.Double, reflect.TypeOf(float64(0))},
+}
+
+// DType returns the tensor data type of a v2 protocol datatype (e.g. FP32)
+func DType(datatype string) (torch.DType, bool) {
+	for _, dt := range datatypes {
+		if dt.name == datatype {
+			return dt.dataType, true
+		}
+	}
+	return 0, false
+}
+
+// Datatype returns the v2 protocol datatype of a tensor data type
+func Datatype(dt torch.DType) (string, bool) {
+	for _, t := range datatypes {
+		if t.dataType == dt {
+			return t.name, true
+		}
+	}
+	return "", false
+}
+
+func goType(dt torch.DType) reflect.Type {
+	for _, t := range datatypes {
+		if t.dataType == dt {
+			return t.typ
+		}
+	}
+	return nil
+}
+
+// decodeInput creates a tensor from raw (little-endian) contents or typed contents of an input
+func decodeInput(input *inference.ModelInferRequest_InferInputTensor, raw []byte) (*torch.Tensor, error) {
+	dt, ok := DType(input.GetDatatype())
+	if !ok {
+		return nil, fmt.Errorf("unsupported datatype %s", input.GetDatatype())
+	}
+
+	shape := input.GetShape()
+	n, err := numElements(shape)
+	if err != nil {
+		return nil, err
+	}
+
+	// The number of elements is checked against the contents before allocating so that shapes
+	// given by clients can not allocate more memory than the request contains
+	var contents reflect.Value
+	if raw != nil {
+		if input.GetContents() != nil {
+			return nil, fmt.Errorf("both raw and typed contents given")
+		}
+		size := int64(goType(dt).Size())
+		if n > int64(len(raw))/size || int64(len(raw)) != n*size {
+			return nil, fmt.Errorf("raw contents size %d does not match shape %v", len(raw), shape)
+		}
+	} else {
+		contents, err = typedContents(input.GetContents(), dt)
+		if err != nil {
+			return nil, err
+		}
+		if int64(contents.Len()) != n {
+			return nil, fmt.Errorf("contents length %d does not match shape %v", contents.Len(), shape)
+		}
+	}
+
+	data := reflect.MakeSlice(reflect.SliceOf(goType(dt)), int(n), int(n))
+
+	if raw != nil {
+		if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, data.Interface()); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := 0; i < contents.Len(); i++ {
+			data.Index(i).Set(contents.Index(i).Convert(goType(dt)))
+		}
+	}
+
+	if shape == nil {
+		shape = []int64{}
+	}
+
+	return torch.NewTensorWithShape(data.Interface(), shape, dt)
+}
+
+// numElements returns the number of elements of a tensor of given shape
+func numElements(shape []int64) (int64, error) {
+	for _, d := range shape {
+		if d < 0 {
+			return 0, fmt.Errorf("invalid shape %v", shape)
+		}
+	}
+
+	n := int64(1)
+	for _, d := range shape {
+		if d == 0 {
+			return 0, nil
+		}
+		if n > math.MaxInt64/d {
+			return 0, fmt.Errorf("shape %v is too large", shape)
+		}
+		n *= d
+	}
+
+	return n, nil
+}
+
+// typedContents returns the contents field used by the datatype (8 and 32 bit integers share a field)
+func typedContents(contents *inference.InferTensorContents, dt torch.DType) (reflect.Value, error) {
+	switch dt {
+	case torch.Byte:
+		return reflect.ValueOf(contents.GetUintContents()), nil
+	case torch.Char, torch.Int:
+		return reflect.ValueOf(contents.GetIntContents()), nil
+	case torch.Long:
+		return reflect.ValueOf(contents.GetInt64Contents()), nil
+	case torch.Float:
+		return reflect.ValueOf(contents.GetFp32Contents()), nil
+	case torch.Double:
+		return reflect.ValueOf(contents.GetFp64Contents()), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported DType %d", int(dt))
+	}
+}
+
+// encodeOutput encodes a tensor as an output with raw (little-endian) contents
+func encodeOutput(name string, t *torch.Tensor) (*inference.ModelInferResponse_InferOutputTensor, []byte, error) {
+	dt, err := t.DTypeE()
+	if err != nil {
+		return nil, nil, err
+	}
+	datatype, ok := Datatype(dt)
+	if !ok {
+		return nil, nil, fmt.Errorf("output %s has unsupported DType %d", name, int(dt))
+	}
+
+	// The shape is copied as the response is encoded after the tensor may have been freed
+	shape, err := t.ShapeE()
+	if err != nil {
+		return nil, nil, err
+	}
+	shape = append([]int64(nil), shape...)
+	n := 1
+	for _, d := range shape {
+		n *= int(d)
+	}
+
+	value, err := t.ValueE()
+	if err != nil {
+		return nil, nil, fmt.Errorf("output %s: %w", name, err)
+	}
+
+	data := reflect.MakeSlice(reflect.SliceOf(goType(dt)), 0, n)
+	data = flattenValue(reflect.ValueOf(value), data)
+
+	var buf bytes.Buffer
+	buf.Grow(n * int(goType(dt).Size()))
+	if err := binary.Write(&buf, binary.LittleEndian, data.Interface()); err != nil {
+		return nil, nil, err
+	}
+
+	return &inference.ModelInferResponse_InferOutputTensor{
+		Name:     name,
+		Datatype: datatype,
+		Shape:    shape,
+	}, buf.Bytes(), nil
+}
+
+// flattenValue appends the elements of a (nested) slice returned by Tensor.Value to data
+func flattenValue(v reflect.Value, data reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Slice {
+		return reflect.Append(data, v)
+	}
+
+	if v.Type().Elem().Kind() != reflect.Slice {
+		return reflect.AppendSlice(data, v)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		data = flattenValue(v.Index(i), data)
+	}
+	return data
+}
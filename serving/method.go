@@ -0,0 +1,15 @@
+package serving
+
+import "github.com/orktes/go-torch"
+
+// MethodInputs returns the arguments callers have to provide to a method (self is bound by the module)
+func MethodInputs(method *torch.JITModuleMethod) ([]torch.JITModuleMethodArgument, error) {
+	args, err := method.ArgumentsE()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && args[0].Name == "self" {
+		args = args[1:]
+	}
+	return args, nil
+}
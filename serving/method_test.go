@@ -0,0 +1,33 @@
+package serving
+
+import (
+	"testing"
+
+	"github.com/orktes/go-torch"
+)
+
+func Test_MethodInputs(t *testing.T) {
+	module, err := torch.CompileTorchScript(`
+def forward(a, n: int):
+	return a + n
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, err := module.GetMethod("forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := MethodInputs(method)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(args) != 2 || args[0].Name != "a" || args[1].Name != "n" {
+		t.Error("wrong inputs", args)
+	}
+	if _, ok := args[1].TypeInfo.(torch.IntType); !ok {
+		t.Error("wrong type", args[1].TypeInfo)
+	}
+}
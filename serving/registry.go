@@ -0,0 +1,397 @@
+// Package serving manages TorchScript models for long running inference services
+package serving
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/orktes/go-torch"
+)
+
+// ModelFileName is the file loaded from each version directory by ModelRegistry.Scan
+const ModelFileName = "model.pt"
+
+// ErrModelNotFound is returned when a model (or model version) is not in the registry
+var ErrModelNotFound = errors.New("model not found")
+
+// ErrNoPreviousVersion is returned by Rollback when there is no version to roll back to
+var ErrNoPreviousVersion = errors.New("no previous version to roll back to")
+
+// ModelRegistry holds TorchScript modules by name and version. Each name has one active version
+// which receives all traffic. Switching versions is atomic: calls started before the switch finish on
+// the old module, which the registry releases (leaving it to be freed by the garbage collector once
+// no other references remain) after those calls have drained.
+type ModelRegistry struct {
+	// OnError is called for errors encountered by Watch (optional)
+	OnError func(err error)
+
+	mutex  sync.RWMutex
+	models map[string]*registeredModel
+
+	// load loads modules (torch.LoadJITModule)
+	load func(path string) (*torch.JITModule, error)
+}
+
+type registeredModel struct {
+	versions map[string]*ModelVersion
+	active   *ModelVersion
+	// history contains previously active versions (most recent last) for rollbacks
+	history []string
+}
+
+// ModelVersion is a single version of a model
+type ModelVersion struct {
+	Name    string
+	Version string
+	Path    string
+
+	mutex    sync.Mutex
+	drained  *sync.Cond
+	inflight int
+	module   *torch.JITModule
+}
+
+// NewModelRegistry returns an empty ModelRegistry
+func NewModelRegistry() *ModelRegistry {
+	return &ModelRegistry{
+		models: map[string]*registeredModel{},
+		load:   torch.LoadJITModule,
+	}
+}
+
+// Load loads a model version from path and makes it the active version
+func (r *ModelRegistry) Load(name, version, path string) error {
+	return r.loadVersion(name, version, path, false)
+}
+
+// Activate switches traffic to a version which has been loaded before. Versions which have been
+// released are loaded again from their path.
+func (r *ModelRegistry) Activate(name, version string) error {
+	r.mutex.RLock()
+	model, ok := r.models[name]
+	var mv *ModelVersion
+	if ok {
+		mv = model.versions[version]
+	}
+	active := ok && model.active == mv
+	r.mutex.RUnlock()
+
+	if mv == nil {
+		return fmt.Errorf("%w: %s version %s", ErrModelNotFound, name, version)
+	}
+	if active {
+		return nil
+	}
+
+	return r.loadVersion(name, version, mv.Path, false)
+}
+
+// Rollback switches traffic back to the previously active version
+func (r *ModelRegistry) Rollback(name string) error {
+	r.mutex.RLock()
+	model, ok := r.models[name]
+	var mv *ModelVersion
+	if ok && len(model.history) > 0 {
+		mv = model.versions[model.history[len(model.history)-1]]
+	}
+	r.mutex.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, name)
+	}
+	if mv == nil {
+		return ErrNoPreviousVersion
+	}
+
+	return r.loadVersion(name, mv.Version, mv.Path, true)
+}
+
+func (r *ModelRegistry) loadVersion(name, version, path string, rollback bool) error {
+	module, err := r.load(path)
+	if err != nil {
+		return fmt.Errorf("unable to load model %s version %s: %w", name, version, err)
+	}
+
+	mv := &ModelVersion{Name: name, Version: version, Path: path, module: module}
+	mv.drained = sync.NewCond(&mv.mutex)
+
+	r.mutex.Lock()
+	model, ok := r.models[name]
+	if !ok {
+		model = &registeredModel{versions: map[string]*ModelVersion{}}
+		r.models[name] = model
+	}
+	model.versions[version] = mv
+	previous := r.activate(model, mv, rollback)
+	r.mutex.Unlock()
+
+	r.release(previous)
+
+	return nil
+}
+
+// activate makes mv the active version of the model and returns the previously active version.
+// Rollbacks remove the version from the history instead of recording the version rolled back from.
+// (r.mutex must be held)
+func (r *ModelRegistry) activate(model *registeredModel, mv *ModelVersion, rollback bool) *ModelVersion {
+	previous := model.active
+	model.active = mv
+
+	if rollback {
+		if n := len(model.history); n > 0 && model.history[n-1] == mv.Version {
+			model.history = model.history[:n-1]
+		}
+	} else if previous != nil && previous.Version != mv.Version {
+		model.history = append(model.history, previous.Version)
+	}
+
+	return previous
+}
+
+// release waits until calls on a version are done and drops its module
+func (r *ModelRegistry) release(mv *ModelVersion) {
+	if mv == nil {
+		return
+	}
+
+	mv.mutex.Lock()
+	for mv.inflight > 0 {
+		mv.drained.Wait()
+	}
+	mv.module = nil
+	mv.mutex.Unlock()
+}
+
+// Module returns the loaded module (nil once the version has been released)
+func (mv *ModelVersion) Module() *torch.JITModule {
+	mv.mutex.Lock()
+	defer mv.mutex.Unlock()
+
+	return mv.module
+}
+
+// Acquire returns the active version of a model. Its module stays loaded until release is called,
+// which must be called once the caller is done with the module.
+func (r *ModelRegistry) Acquire(name string) (mv *ModelVersion, release func(), err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	model, ok := r.models[name]
+	if !ok || model.active == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrModelNotFound, name)
+	}
+
+	mv = model.active
+	mv.mutex.Lock()
+	mv.inflight++
+	mv.mutex.Unlock()
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			mv.mutex.Lock()
+			mv.inflight--
+			if mv.inflight == 0 {
+				mv.drained.Broadcast()
+			}
+			mv.mutex.Unlock()
+		})
+	}
+
+	return mv, release, nil
+}
+
+// Run runs a method of the active version of a model
+func (r *ModelRegistry) Run(name, method string, inputs ...interface{}) (interface{}, error) {
+	mv, release, err := r.Acquire(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return mv.Module().RunMethod(method, inputs...)
+}
+
+// RunContext runs a method of the active version of a model respecting ctx. If ctx is done while
+// the method is running, RunContext returns ctx.Err() right away and the computation finishes in
+// the background (LibTorch can not interrupt it). The model version is held until the computation
+// has finished so that switching versions still waits for it. The number of computations left
+// running this way is not bounded by the registry.
+func (r *ModelRegistry) RunContext(ctx context.Context, name, method string, inputs ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mv, release, err := r.Acquire(name)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		defer release()
+
+		value, err := mv.Module().RunMethod(method, inputs...)
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Models returns the names of all models
+func (r *ModelRegistry) Models() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.models))
+	for name := range r.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Versions returns all known versions of a model in version order
+func (r *ModelRegistry) Versions(name string) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	model, ok := r.models[name]
+	if !ok {
+		return nil
+	}
+
+	versions := make([]string, 0, len(model.versions))
+	for version := range model.versions {
+		versions = append(versions, version)
+	}
+	sortVersions(versions)
+
+	return versions
+}
+
+// ActiveVersion returns the active version of a model
+func (r *ModelRegistry) ActiveVersion(name string) (string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	model, ok := r.models[name]
+	if !ok || model.active == nil {
+		return "", false
+	}
+
+	return model.active.Version, true
+}
+
+// Scan loads new model versions from a directory laid out as <dir>/<name>/<version>/model.pt
+// (the Triton model repository layout). For each model the latest version (numeric versions are
+// compared as numbers) is loaded and activated if it has not been seen before, so that versions
+// activated by Activate or Rollback are not overridden until a new version appears.
+func (r *ModelRegistry) Scan(dir string) error {
+	modelDirs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, modelDir := range modelDirs {
+		if !modelDir.IsDir() {
+			continue
+		}
+		name := modelDir.Name()
+
+		versionDirs, err := ioutil.ReadDir(filepath.Join(dir, name))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		var versions []string
+		for _, versionDir := range versionDirs {
+			path := filepath.Join(dir, name, versionDir.Name(), ModelFileName)
+			if _, err := os.Stat(path); versionDir.IsDir() && err == nil {
+				versions = append(versions, versionDir.Name())
+			}
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		sortVersions(versions)
+		latest := versions[len(versions)-1]
+
+		r.mutex.RLock()
+		model, ok := r.models[name]
+		seen := ok && model.versions[latest] != nil
+		r.mutex.RUnlock()
+
+		if seen {
+			continue
+		}
+
+		if err := r.Load(name, latest, filepath.Join(dir, name, latest, ModelFileName)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("scanning %s failed: %s", dir, errs)
+	}
+
+	return nil
+}
+
+// Watch scans a directory (see Scan) every interval until ctx is done. Scan errors are passed
+// to OnError and do not stop watching.
+func (r *ModelRegistry) Watch(ctx context.Context, dir string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Scan(dir); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sortVersions sorts numeric versions as numbers (before other versions, which are sorted as strings)
+func sortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		a, errA := strconv.ParseInt(versions[i], 10, 64)
+		b, errB := strconv.ParseInt(versions[j], 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			return a < b
+		case errA == nil:
+			return true
+		case errB == nil:
+			return false
+		default:
+			return versions[i] < versions[j]
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package serving
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/orktes/go-torch"
+)
+
+// saveVersion saves a module returning a + version to <dir>/<name>/<version>/model.pt
+func saveVersion(t *testing.T, dir, name, version string) string {
+	module, err := torch.CompileTorchScript(`
+def forward(a):
+	return a + ` + version + `
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versionDir := filepath.Join(dir, name, version)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(versionDir, ModelFileName)
+	if err := module.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func runValue(t *testing.T, r *ModelRegistry, name string) float32 {
+	input, _ := torch.NewTensor([]float32{0})
+	res, err := r.Run(name, "forward", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.(*torch.Tensor).Value().([]float32)[0]
+}
+
+func Test_ModelRegistry(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "registry")
+	defer os.RemoveAll(dir)
+
+	r := NewModelRegistry()
+	if err := r.Load("add", "1", saveVersion(t, dir, "add", "1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Load("add", "2", saveVersion(t, dir, "add", "2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := runValue(t, r, "add"); v != 2 {
+		t.Error("version 2 should be active", v)
+	}
+
+	if err := r.Rollback("add"); err != nil {
+		t.Fatal(err)
+	}
+	if v := runValue(t, r, "add"); v != 1 {
+		t.Error("version 1 should be active after rollback", v)
+	}
+	if err := r.Rollback("add"); err != ErrNoPreviousVersion {
+		t.Error("expected no previous version error", err)
+	}
+
+	if err := r.Activate("add", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := r.ActiveVersion("add"); version != "2" {
+		t.Error("version 2 should be active", version)
+	}
+
+	if !reflect.DeepEqual(r.Versions("add"), []string{"1", "2"}) {
+		t.Error("wrong versions", r.Versions("add"))
+	}
+
+	if _, err := r.Run("missing", "forward"); !errors.Is(err, ErrModelNotFound) {
+		t.Error("should return ErrModelNotFound for missing models", err)
+	}
+	if err := r.Activate("add", "3"); !errors.Is(err, ErrModelNotFound) {
+		t.Error("should return ErrModelNotFound for missing versions", err)
+	}
+	if err := r.Rollback("missing"); !errors.Is(err, ErrModelNotFound) {
+		t.Error("should return ErrModelNotFound for missing models", err)
+	}
+}
+
+func Test_ModelRegistryDrain(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "registry")
+	defer os.RemoveAll(dir)
+
+	r := NewModelRegistry()
+	if err := r.Load("add", "1", saveVersion(t, dir, "add", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	mv, release, err := r.Acquire("add")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := saveVersion(t, dir, "add", "2")
+
+	loaded := make(chan struct{})
+	go func() {
+		r.Load("add", "2", path)
+		close(loaded)
+	}()
+
+	// The switch happens right away but the old version is kept until released
+	for {
+		if version, _ := r.ActiveVersion("add"); version == "2" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-loaded:
+		t.Fatal("load should wait for in-flight calls")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if mv.Module() == nil {
+		t.Fatal("module should not be released before in-flight calls are done")
+	}
+
+	release()
+	<-loaded
+
+	if mv.Module() != nil {
+		t.Error("module should be released")
+	}
+}
+
+func Test_ModelRegistryRunContext(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "registry")
+	defer os.RemoveAll(dir)
+
+	r := NewModelRegistry()
+	if err := r.Load("add", "1", saveVersion(t, dir, "add", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	input, _ := torch.NewTensor([]float32{0})
+	res, err := r.RunContext(ctx, "add", "forward", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := res.(*torch.Tensor).Value().([]float32)[0]; v != 1 {
+		t.Error("wrong result", v)
+	}
+
+	cancel()
+	if _, err := r.RunContext(ctx, "add", "forward", input); err != context.Canceled {
+		t.Error("expected context.Canceled", err)
+	}
+
+	// Versions acquired by RunContext are released once the computation has finished
+	path := saveVersion(t, dir, "add", "2")
+	loaded := make(chan error, 1)
+	go func() {
+		loaded <- r.Load("add", "2", path)
+	}()
+
+	select {
+	case err := <-loaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("load should not wait after RunContext has returned")
+	}
+}
+
+func Test_ModelRegistryScan(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "registry")
+	defer os.RemoveAll(dir)
+
+	saveVersion(t, dir, "add", "2")
+	saveVersion(t, dir, "add", "10")
+
+	r := NewModelRegistry()
+	if err := r.Scan(dir); err != nil {
+		t.Fatal(err)
+	}
+	if v := runValue(t, r, "add"); v != 10 {
+		t.Error("latest version should be active", v)
+	}
+
+	// Manual rollbacks are kept until a new version appears
+	r.Load("add", "2", filepath.Join(dir, "add", "2", ModelFileName))
+	r.Scan(dir)
+	if v := runValue(t, r, "add"); v != 2 {
+		t.Error("version 2 should stay active", v)
+	}
+
+	saveVersion(t, dir, "add", "11")
+	if err := r.Scan(dir); err != nil {
+		t.Fatal(err)
+	}
+	if v := runValue(t, r, "add"); v != 11 {
+		t.Error("new version should be active", v)
+	}
+}
+
+func Test_SortVersions(t *testing.T) {
+	versions := []string{"b", "10", "2", "a", "1"}
+	sortVersions(versions)
+	if !reflect.DeepEqual(versions, []string{"1", "2", "10", "a", "b"}) {
+		t.Error("wrong order", versions)
+	}
+}
@@ -0,0 +1,149 @@
+package torch
+
+// #include "torch.hpp"
+// #include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// StateDictError is returned by LoadStateDict when given tensors do not match module parameters
+type StateDictError struct {
+	// Missing parameters that were not present in the state dict
+	Missing []string
+	// Unexpected keys in the state dict that do not match any parameter
+	Unexpected []string
+	// Mismatched tensors with a different shape than the matching parameter
+	Mismatched []ShapeMismatch
+}
+
+// IncompatibleKeys lists the keys of a state dict which did not match module parameters
+type IncompatibleKeys struct {
+	// Missing parameters that were not present in the state dict
+	Missing []string
+	// Unexpected keys in the state dict that do not match any parameter
+	Unexpected []string
+}
+
+// ShapeMismatch describes a state dict tensor with a different shape than the matching parameter
+type ShapeMismatch struct {
+	Name     string
+	Expected []int64
+	Got      []int64
+}
+
+func (e *StateDictError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing keys: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unexpected) > 0 {
+		parts = append(parts, "unexpected keys: "+strings.Join(e.Unexpected, ", "))
+	}
+	for _, m := range e.Mismatched {
+		parts = append(parts, fmt.Sprintf("size mismatch for %s: expected %v but got %v", m.Name, m.Expected, m.Got))
+	}
+
+	return "error loading state dict: " + strings.Join(parts, "; ")
+}
+
+// Is reports whether target is ShapeMismatchError and tensors with mismatching shapes were given
+func (e *StateDictError) Is(target error) bool {
+	return target == ShapeMismatchError && len(e.Mismatched) > 0
+}
+
+// StateDict returns all parameters and buffers of the module (and its submodules) by name.
+// Returned tensors share memory with the module parameters.
+func (m *JITModule) StateDict() (map[string]*Tensor, error) {
+	var resSize C.ulong
+	var cErr C.Torch_Error
+	resPtr := C.Torch_JITModuleNamedParameters(m.context, &resSize, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(resPtr))
+
+	return convertNamedTensors(resPtr, resSize), nil
+}
+
+// LoadStateDict copies given tensors into parameters and buffers with matching names. In strict
+// mode nothing is copied unless the keys of the state dict match the module parameters exactly.
+// Otherwise matching tensors are copied, the keys which did not match are returned and an error
+// is returned only if shapes do not match. Errors describing mismatching keys are of type *StateDictError.
+func (m *JITModule) LoadStateDict(stateDict map[string]*Tensor, strict bool) (IncompatibleKeys, error) {
+	params, err := m.StateDict()
+	if err != nil {
+		return IncompatibleKeys{}, err
+	}
+
+	dictErr := &StateDictError{}
+
+	for name := range params {
+		if _, ok := stateDict[name]; !ok {
+			dictErr.Missing = append(dictErr.Missing, name)
+		}
+	}
+
+	var names []string
+	for name, value := range stateDict {
+		param, ok := params[name]
+		if !ok {
+			dictErr.Unexpected = append(dictErr.Unexpected, name)
+			continue
+		}
+
+		if !shapeEqual(param.Shape(), value.Shape()) {
+			dictErr.Mismatched = append(dictErr.Mismatched, ShapeMismatch{
+				Name:     name,
+				Expected: append([]int64{}, param.Shape()...),
+				Got:      append([]int64{}, value.Shape()...),
+			})
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(dictErr.Missing)
+	sort.Strings(dictErr.Unexpected)
+	sort.Slice(dictErr.Mismatched, func(i, j int) bool {
+		return dictErr.Mismatched[i].Name < dictErr.Mismatched[j].Name
+	})
+	sort.Strings(names)
+
+	keys := IncompatibleKeys{
+		Missing:    dictErr.Missing,
+		Unexpected: dictErr.Unexpected,
+	}
+
+	if strict && (len(dictErr.Missing) > 0 || len(dictErr.Unexpected) > 0 || len(dictErr.Mismatched) > 0) {
+		return keys, dictErr
+	}
+
+	for _, name := range names {
+		if err := m.SetParameter(name, stateDict[name]); err != nil {
+			return keys, err
+		}
+	}
+
+	if len(dictErr.Mismatched) > 0 {
+		return keys, dictErr
+	}
+
+	return keys, nil
+}
+
+func shapeEqual(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -16,6 +16,8 @@ import (
 type Tensor struct {
 	context C.Torch_TensorContext
 	goData  unsafe.Pointer
+	// mapped is true for tensors created over a memory mapped file (see LoadSafetensors)
+	mapped bool
 }
 
 // NewTensor converts from a Go value to a Tensor. Valid values are scalars, slices, and arrays. Every element of a slice must have the same length so that the resulting Tensor has a valid shape.
@@ -30,21 +32,111 @@ func NewTensor(value interface{}) (*Tensor, error) {
 
 // NewTensorWithShape converts a single dimensional Go array or slice into a Tensor with given shape
 func NewTensorWithShape(value interface{}, shape []int64, dt DType) (*Tensor, error) {
+	val := reflect.ValueOf(value)
+	valueShape, valueType, err := shapeAndDataTypeOf(val)
+	if err != nil {
+		return nil, err
+	}
+
 	nflattened := numElements(shape)
+	if numElements(valueShape) != nflattened {
+		return nil, newError(ShapeMismatchError, "value of shape %v can not be used as a tensor of shape %v", valueShape, shape)
+	}
+	if valueType != dt {
+		return nil, newError(DTypeMismatchError, "value of type %v can not be used as a tensor of DType %d", val.Type(), int(dt))
+	}
+
 	nbytes := typeOf(dt, nil).Size() * uintptr(nflattened)
 	dataPtr := C.malloc(C.size_t(nbytes))
-	dataSlice := (*[1 << 30]byte)(dataPtr)[:nbytes:nbytes]
+	dataSlice := cBytes(dataPtr, nbytes)
 
 	buf := bytes.NewBuffer(dataSlice[:0:nbytes])
-	encodeTensor(buf, reflect.ValueOf(value), shape)
+	if err := encodeTensor(buf, val, valueShape); err != nil {
+		C.free(dataPtr)
+		return nil, err
+	}
+
+	ctx, err := createTensor(dataPtr, shape, dt)
+	if err != nil {
+		C.free(dataPtr)
+		return nil, err
+	}
 
-	ctx := createTensor(dataPtr, shape, dt)
 	t := tensorWithContext(ctx)
 	t.goData = dataPtr
 
 	return t, nil
 }
 
+// NewTensorFromBytes creates a tensor of given shape and DType from raw element data in native byte
+// order and C (row-major) order. Data is copied without reflection, which makes it the fastest way
+// to create large tensors from flat buffers.
+func NewTensorFromBytes(data []byte, shape []int64, dt DType) (*Tensor, error) {
+	if !supportedDType(dt) {
+		return nil, newError(UnsupportedTypeError, "unsupported DType %s", dt)
+	}
+	for _, size := range shape {
+		if size < 0 {
+			return nil, newError(ShapeMismatchError, "invalid tensor shape %v", shape)
+		}
+	}
+
+	return newTensorFromBytes(data, shape, dt)
+}
+
+// newTensorFromBytes creates a tensor from raw data in native byte order (data is copied)
+func newTensorFromBytes(data []byte, shape []int64, dt DType) (*Tensor, error) {
+	nbytes := typeOf(dt, nil).Size() * uintptr(numElements(shape))
+	if uintptr(len(data)) != nbytes {
+		return nil, fmt.Errorf("data size %d does not match %d bytes required by shape %v", len(data), nbytes, shape)
+	}
+
+	dataPtr := C.malloc(C.size_t(nbytes))
+	copy(cBytes(dataPtr, nbytes), data)
+
+	ctx, err := createTensor(dataPtr, shape, dt)
+	if err != nil {
+		C.free(dataPtr)
+		return nil, err
+	}
+
+	t := tensorWithContext(ctx)
+	t.goData = dataPtr
+
+	return t, nil
+}
+
+// rawData returns a copy of the tensor data in native byte order and C (row-major) order
+func (t *Tensor) rawData() ([]byte, error) {
+	dt, err := t.DTypeE()
+	if err != nil {
+		return nil, err
+	}
+	if !supportedDType(dt) {
+		return nil, newError(UnsupportedTypeError, "unsupported DType %s", dt)
+	}
+
+	shape, err := t.ShapeE()
+	if err != nil {
+		return nil, err
+	}
+
+	nbytes := typeOf(dt, nil).Size() * uintptr(numElements(shape))
+	data := make([]byte, nbytes)
+	if nbytes == 0 {
+		return data, nil
+	}
+
+	var cErr C.Torch_Error
+	C.Torch_TensorCopyData(t.context, unsafe.Pointer(&data[0]), C.ulong(nbytes), &cErr)
+	runtime.KeepAlive(t)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
 func tensorWithContext(ctx C.Torch_TensorContext) *Tensor {
 	t := &Tensor{
 		context: ctx,
@@ -55,15 +147,50 @@ func tensorWithContext(ctx C.Torch_TensorContext) *Tensor {
 	return t
 }
 
-// DType returns tensors datatype
+// DType returns tensors datatype. It panics if LibTorch fails (see DTypeE).
 func (t *Tensor) DType() DType {
-	return DType(C.Torch_TensorType(t.context))
+	dt, err := t.DTypeE()
+	if err != nil {
+		panic(err)
+	}
+	return dt
+}
+
+// DTypeE returns tensors datatype
+func (t *Tensor) DTypeE() (DType, error) {
+	var cErr C.Torch_Error
+	dt := DType(C.Torch_TensorType(t.context, &cErr))
+	runtime.KeepAlive(t)
+	if err := checkError(cErr); err != nil {
+		return 0, err
+	}
+
+	return dt, nil
 }
 
-// Value returns tensors value as a go type
+// Value returns tensors value as a go type. It panics if the value can not be read (see ValueE).
 func (t *Tensor) Value() interface{} {
-	dt := t.DType()
-	shape := t.Shape()
+	val, err := t.ValueE()
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// ValueE returns tensors value as a go type
+func (t *Tensor) ValueE() (interface{}, error) {
+	dt, err := t.DTypeE()
+	if err != nil {
+		return nil, err
+	}
+	if !supportedDType(dt) {
+		return nil, newError(UnsupportedTypeError, "unsupported DType %d", int(dt))
+	}
+
+	shape, err := t.ShapeE()
+	if err != nil {
+		return nil, err
+	}
 
 	typ := typeOf(dt, shape)
 	val := reflect.New(typ)
@@ -71,22 +198,154 @@ func (t *Tensor) Value() interface{} {
 	nflattened := numElements(shape)
 	nbytes := typeOf(dt, nil).Size() * uintptr(nflattened)
 
-	dataPtr := C.Torch_TensorValue(t.context)
-	dataSlice := (*[1 << 30]byte)(dataPtr)[:nbytes:nbytes]
+	var cErr C.Torch_Error
+	dataPtr := C.Torch_TensorValue(t.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+	dataSlice := cBytes(dataPtr, nbytes)
 
 	if err := decodeTensor(bytes.NewReader(dataSlice), shape, typ, val); err != nil {
-		panic(fmt.Sprintf("unable to decode Tensor of type %v and shape %v - %v", dt, shape, err))
+		return nil, fmt.Errorf("unable to decode Tensor of type %v and shape %v - %w", dt, shape, err)
 	}
+	runtime.KeepAlive(t)
 
-	return reflect.Indirect(val).Interface()
+	return reflect.Indirect(val).Interface(), nil
 }
 
-// Shape returns tensors shape
+// Shape returns tensors shape. It panics if LibTorch fails (see ShapeE).
 func (t *Tensor) Shape() []int64 {
+	shape, err := t.ShapeE()
+	if err != nil {
+		panic(err)
+	}
+	return shape
+}
+
+// ShapeE returns tensors shape
+func (t *Tensor) ShapeE() ([]int64, error) {
+	var size C.ulong
+	var cErr C.Torch_Error
+	shape := C.Torch_TensorShape(t.context, &size, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return copyInt64s(shape, size, t), nil
+}
+
+// cBytes returns the n bytes of C memory at ptr as a slice. Unlike a cast to a fixed size array
+// type (e.g. *[1 << 30]byte) it can address buffers of any size.
+func cBytes(ptr unsafe.Pointer, n uintptr) []byte {
+	var b []byte
+	if n == 0 {
+		return b
+	}
+
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	hdr.Data = uintptr(ptr)
+	hdr.Len = int(n)
+	hdr.Cap = int(n)
+	return b
+}
+
+// copyInt64s copies n values from memory owned by owner
+func copyInt64s(ptr *C.int64_t, n C.ulong, owner interface{}) []int64 {
+	res := make([]int64, int(n))
+	if n > 0 {
+		copy(res, (*[1 << 30]int64)(unsafe.Pointer(ptr))[:n:n])
+	}
+	runtime.KeepAlive(owner)
+
+	return res
+}
+
+// Strides returns the number of elements to skip in each dimension to get to the next element.
+// It panics if LibTorch fails (see StridesE).
+func (t *Tensor) Strides() []int64 {
+	strides, err := t.StridesE()
+	if err != nil {
+		panic(err)
+	}
+	return strides
+}
+
+// StridesE returns the number of elements to skip in each dimension to get to the next element
+func (t *Tensor) StridesE() ([]int64, error) {
 	var size C.ulong
-	shape := C.Torch_TensorShape(t.context, &size)
-	slice := (*[1 << 30]int64)(unsafe.Pointer(shape))[:size:size]
-	return slice
+	var cErr C.Torch_Error
+	strides := C.Torch_TensorStrides(t.context, &size, &cErr)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return copyInt64s(strides, size, t), nil
+}
+
+// SetRequiresGrad sets whether autograd should record operations on the tensor
+func (t *Tensor) SetRequiresGrad(requiresGrad bool) error {
+	var cErr C.Torch_Error
+	C.Torch_TensorSetRequiresGrad(t.context, cBool(requiresGrad), &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RequiresGrad returns true if autograd records operations on the tensor. It panics if LibTorch fails (see RequiresGradE).
+func (t *Tensor) RequiresGrad() bool {
+	requiresGrad, err := t.RequiresGradE()
+	if err != nil {
+		panic(err)
+	}
+	return requiresGrad
+}
+
+// RequiresGradE returns true if autograd records operations on the tensor
+func (t *Tensor) RequiresGradE() (bool, error) {
+	var cErr C.Torch_Error
+	requiresGrad := C.Torch_TensorRequiresGrad(t.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return false, err
+	}
+
+	return requiresGrad != 0, nil
+}
+
+// Grad returns the gradient accumulated for the tensor (nil if there is none). It panics if LibTorch fails (see GradE).
+func (t *Tensor) Grad() *Tensor {
+	grad, err := t.GradE()
+	if err != nil {
+		panic(err)
+	}
+	return grad
+}
+
+// GradE returns the gradient accumulated for the tensor (nil if there is none)
+func (t *Tensor) GradE() (*Tensor, error) {
+	var cErr C.Torch_Error
+	ctx := C.Torch_TensorGrad(t.context, &cErr)
+	runtime.KeepAlive(t)
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		return nil, nil
+	}
+
+	return tensorWithContext(ctx), nil
+}
+
+// Backward computes the gradients of the tensor with respect to graph leaves
+func (t *Tensor) Backward() error {
+	var cErr C.Torch_Error
+	C.Torch_TensorBackward(t.context, &cErr)
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (t *Tensor) finalize() {
@@ -96,17 +355,23 @@ func (t *Tensor) finalize() {
 	}
 }
 
-func createTensor(ptr unsafe.Pointer, shape []int64, dtype DType) C.Torch_TensorContext {
+func createTensor(ptr unsafe.Pointer, shape []int64, dtype DType) (C.Torch_TensorContext, error) {
 	var shapePtr *C.int64_t
 	if len(shape) > 0 {
 		shapePtr = (*C.int64_t)(unsafe.Pointer(&shape[0]))
 	}
-	ctx := C.Torch_NewTensor(ptr, shapePtr, C.int(len(shape)), C.Torch_DataType(dtype))
+
+	var cErr C.Torch_Error
+	ctx := C.Torch_NewTensor(ptr, shapePtr, C.int(len(shape)), C.Torch_DataType(dtype), &cErr)
 
 	runtime.KeepAlive(shape)
 	runtime.KeepAlive(ptr)
 
-	return ctx
+	if err := checkError(cErr); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
 }
 
 // shapeAndDataTypeOf returns the data type and shape of the Tensor
@@ -129,7 +394,7 @@ func shapeAndDataTypeOf(val reflect.Value) (shape []int64, dt DType, err error)
 			return shape, DType(t.dataType), nil
 		}
 	}
-	return shape, dt, fmt.Errorf("unsupported type %v", typ)
+	return shape, dt, newError(UnsupportedTypeError, "unsupported type %v", typ)
 }
 
 // decodeTensor decodes the Tensor from the buffer to ptr using the format
@@ -166,7 +431,7 @@ func decodeTensor(r *bytes.Reader, shape []int64, typ reflect.Type, ptr reflect.
 		}
 
 	default:
-		return fmt.Errorf("unsupported type %v", typ)
+		return newError(UnsupportedTypeError, "unsupported type %v", typ)
 	}
 	return nil
 }
@@ -213,11 +478,21 @@ func encodeTensor(w *bytes.Buffer, v reflect.Value, shape []int64) error {
 		}
 
 	default:
-		return fmt.Errorf("unsupported type %v", v.Type())
+		return newError(UnsupportedTypeError, "unsupported type %v", v.Type())
 	}
 	return nil
 }
 
+// supportedDType reports whether values of given DType can be converted to Go values
+func supportedDType(dt DType) bool {
+	for _, t := range types {
+		if dt == DType(t.dataType) {
+			return true
+		}
+	}
+	return false
+}
+
 // typeOf converts from a DType and Shape to the equivalent Go type.
 func typeOf(dt DType, shape []int64) reflect.Type {
 	var ret reflect.Type
@@ -261,15 +536,41 @@ func init() {
 	}
 }
 
-// PrintTensors prints tensors contents
-func PrintTensors(inputs ...*Tensor) {
+// PrintTensors prints tensors contents to the standard output of the C++ runtime. Use String or
+// the fmt package to print tensors from Go.
+func PrintTensors(inputs ...*Tensor) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+
 	contexts := make([]C.Torch_TensorContext, len(inputs))
 	for i, t := range inputs {
 		contexts[i] = t.context
 	}
 
-	C.Torch_PrintTensors((*C.Torch_TensorContext)(&contexts[0]), C.ulong(len(contexts)))
+	var cErr C.Torch_Error
+	C.Torch_PrintTensors((*C.Torch_TensorContext)(&contexts[0]), C.ulong(len(contexts)), &cErr)
 
 	runtime.KeepAlive(inputs)
 
+	if err := checkError(cErr); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func cBool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func tensorContexts(tensors []*Tensor) []C.Torch_TensorContext {
+	contexts := make([]C.Torch_TensorContext, len(tensors))
+	for i, t := range tensors {
+		contexts[i] = t.context
+	}
+	return contexts
 }
@@ -1,18 +1,85 @@
 package torch
 
 import (
+	"errors"
+	"math"
+	"reflect"
 	"testing"
 	"unsafe"
 )
 
 func Test_createTensor(t *testing.T) {
 	data := []float32{1, 2}
-	ctx := createTensor(unsafe.Pointer(&data[0]), []int64{2}, Float)
+	ctx, err := createTensor(unsafe.Pointer(&data[0]), []int64{2}, Float)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if ctx == nil {
 		t.Error("should have returned an array")
 	}
 }
 
+func Test_createTensorErrors(t *testing.T) {
+	data := []float32{1, 2}
+
+	if _, err := createTensor(unsafe.Pointer(&data[0]), []int64{-1, -2}, Float); err == nil {
+		t.Error("should return an error for negative sizes")
+	}
+	if _, err := createTensor(unsafe.Pointer(&data[0]), []int64{2}, DType(0)); err == nil {
+		t.Error("should return an error for an unknown data type")
+	}
+	if _, err := newTensorFromBytes(make([]byte, 4), []int64{-1, -1}, Float); err == nil {
+		t.Error("should return an error for negative sizes")
+	}
+}
+
+func Test_ValueE(t *testing.T) {
+	module, err := CompileTorchScript(`
+def half(a):
+	return a.half()
+
+def sparse(a):
+	return a.to_sparse()
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := NewTensor([]float32{1, 2})
+
+	val, err := a.ValueE()
+	if err != nil || !reflect.DeepEqual(val, []float32{1, 2}) {
+		t.Error("wrong value", val, err)
+	}
+
+	res, err := module.RunMethod("half", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := res.(*Tensor)
+
+	if _, err := half.ValueE(); !errors.Is(err, UnsupportedTypeError) {
+		t.Error("expected an unsupported type error but got", err)
+	}
+
+	func() {
+		defer func() {
+			if _, ok := recover().(*Error); !ok {
+				t.Error("Value should panic with the error")
+			}
+		}()
+		half.Value()
+	}()
+
+	res, err = module.RunMethod("sparse", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.(*Tensor).ValueE(); err == nil {
+		t.Error("expected an error reading the data of a sparse tensor")
+	}
+}
+
 func Test_NewTensor(t *testing.T) {
 	tensor, err := NewTensor([]float32{1, 2})
 	if err != nil {
@@ -39,12 +106,40 @@ func Test_NewTensor(t *testing.T) {
 	}
 }
 
+func Test_NewTensorWithShape(t *testing.T) {
+	tensor, err := NewTensorWithShape([]float32{1, 2, 3, 4, 5, 6}, []int64{2, 3}, Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := tensor.Value().([][]float32)
+	if len(val) != 2 || val[1][0] != 4 {
+		t.Error("wrong value returned by tensor", val)
+	}
+
+	if _, err := NewTensorWithShape([]float32{1, 2}, []int64{2, 3}, Float); err == nil {
+		t.Error("should return an error for mismatching number of elements")
+	}
+
+	if _, err := NewTensorWithShape([]int32{1, 2}, []int64{2}, Float); err == nil {
+		t.Error("should return an error for mismatching type")
+	}
+
+	if _, err := NewTensor([][]float32{{1, 2}, {3}}); err == nil {
+		t.Error("should return an error for mismatching slice lengths")
+	}
+}
+
 func Test_PrintTensors(t *testing.T) {
 	a, _ := NewTensor([]float32{1, 2})
 	b, _ := NewTensor([]float32{1, 2})
 
-	PrintTensors(a, b)
-
+	if err := PrintTensors(a, b); err != nil {
+		t.Error(err)
+	}
+	if err := PrintTensors(); err != nil {
+		t.Error(err)
+	}
 }
 
 func Benchmark_NewTensor(b *testing.B) {
@@ -52,3 +147,27 @@ func Benchmark_NewTensor(b *testing.B) {
 		NewTensor([]float32{1, 2})
 	}
 }
+
+func Test_NewTensorFromBytes(t *testing.T) {
+	data := make([]byte, 8)
+	nativeEndian.PutUint32(data, math.Float32bits(1.5))
+	nativeEndian.PutUint32(data[4:], math.Float32bits(-2))
+
+	tensor, err := NewTensorFromBytes(data, []int64{2, 1}, Float)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tensor.Value(), [][]float32{{1.5}, {-2}}) {
+		t.Error("wrong value", tensor.Value())
+	}
+
+	if _, err := NewTensorFromBytes(data, []int64{3}, Float); err == nil {
+		t.Error("should return an error for mismatching data size")
+	}
+	if _, err := NewTensorFromBytes(data, []int64{-1, -2}, Float); err == nil {
+		t.Error("should return an error for negative sizes")
+	}
+	if _, err := NewTensorFromBytes(data, []int64{4}, DType(6)); err == nil {
+		t.Error("should return an error for unsupported DTypes")
+	}
+}
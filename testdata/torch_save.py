@@ -0,0 +1,20 @@
+# Generates torch_save.pt, the torch.save fixture loaded by Test_LoadTorchSave.
+#
+#   python3 testdata/torch_save.py
+import os
+
+import torch
+
+weight = torch.arange(6, dtype=torch.float32).reshape(2, 3)
+
+state = {
+    "fc.weight": weight,
+    # Views share the storage of weight
+    "fc.weight_t": weight.t(),
+    "fc.row": weight[1],
+    "fc.bias": torch.tensor([0.5, -1.5], dtype=torch.float64),
+    "step": torch.tensor(3),
+    "scale": torch.nn.Parameter(torch.tensor([2.0])),
+}
+
+torch.save({"model": state}, os.path.join(os.path.dirname(__file__), "torch_save.pt"))
@@ -5,8 +5,19 @@
 #include "torch.hpp"
 #include <iostream>
 #include <stdlib.h>
+#include <atomic>
 #include <exception>
+#include <fstream>
+#include <functional>
+#include <iterator>
+#include <mutex>
+#include <sstream>
+#include <stdexcept>
 #include <string>
+#include <unordered_map>
+#if defined(__linux__) || defined(__APPLE__)
+#include <sys/mman.h>
+#endif
 
 #define HANDLE_TH_ERRORS                                           \
   try {
@@ -14,10 +25,15 @@
   }                                                                \
   catch (const torch::Error& e) {                                  \
     auto msg = e.what_without_backtrace();                         \
+    std::string full = e.what();                                   \
+    auto backtrace = full.compare(0, strlen(msg), msg) == 0        \
+        ? full.substr(strlen(msg)) : std::string();                \
     auto err = Torch_Error{                                        \
         .message = new char[strlen(msg)+1],                        \
+        .backtrace = new char[backtrace.length()+1],               \
     };                                                             \
     std::strcpy(err.message, msg);                                 \
+    std::strcpy(err.backtrace, backtrace.c_str());                 \
     *errVar = err;                                                 \
     return retVal;                                                 \
   }                                                                \
@@ -32,6 +48,31 @@
   }
 
 
+// Failpoints let tests provoke errors from bridge functions which do not fail otherwise.
+// Torch_SetFailpoint arms a function by name and its next call throws.
+static std::atomic<bool> Torch_FailpointArmed(false);
+static std::mutex Torch_FailpointMutex;
+static std::string Torch_FailpointName;
+
+void Torch_SetFailpoint(char* name) {
+    std::lock_guard<std::mutex> lock(Torch_FailpointMutex);
+    Torch_FailpointName = name;
+    Torch_FailpointArmed = !Torch_FailpointName.empty();
+}
+
+static void Torch_CheckFailpoint(const char* name) {
+    if (!Torch_FailpointArmed) {
+        return;
+    }
+
+    std::lock_guard<std::mutex> lock(Torch_FailpointMutex);
+    if (Torch_FailpointName == name) {
+        Torch_FailpointName.clear();
+        Torch_FailpointArmed = false;
+        throw std::runtime_error(std::string("failpoint ") + name);
+    }
+}
+
 struct Torch_Tensor {
     torch::Tensor tensor;
 };
@@ -44,6 +85,43 @@ struct Torch_JITModule_Method {
     torch::jit::script::Method& run;
 };
 
+struct Torch_NNModule {
+    std::shared_ptr<torch::nn::Module> module;
+    std::function<torch::Tensor(torch::Tensor)> forward;
+};
+
+// LibTorch does not ship a LayerNorm module so it is implemented here on top of torch::layer_norm
+struct Torch_LayerNormImpl : torch::nn::Module {
+    Torch_LayerNormImpl(std::vector<int64_t> normalized_shape, double eps, bool affine)
+        : normalized_shape(normalized_shape), eps(eps) {
+        if (affine) {
+            weight = register_parameter("weight", torch::ones(normalized_shape));
+            bias = register_parameter("bias", torch::zeros(normalized_shape));
+        }
+    }
+
+    torch::Tensor forward(torch::Tensor input) {
+        return torch::layer_norm(input, normalized_shape, weight, bias, eps);
+    }
+
+    std::vector<int64_t> normalized_shape;
+    double eps;
+    torch::Tensor weight;
+    torch::Tensor bias;
+};
+
+struct Torch_Optimizer {
+    std::shared_ptr<torch::optim::Optimizer> optimizer;
+    std::function<double()> get_lr;
+    std::function<void(double)> set_lr;
+};
+
+char* Torch_CopyString(const std::string& str) {
+    auto cstr = (char*)malloc(str.length() + 1);
+    strcpy(cstr, str.c_str());
+    return cstr;
+}
+
 torch::TensorOptions Torch_ConvertDataTypeToOptions(Torch_DataType dtype) {
     torch::TensorOptions options;
     switch (dtype) {
@@ -72,8 +150,7 @@ torch::TensorOptions Torch_ConvertDataTypeToOptions(Torch_DataType dtype) {
         options = torch::TensorOptions(torch::kDouble);
         break;
         default:
-        // TODO handle other types
-        break;
+        throw std::invalid_argument("unsupported data type " + std::to_string(dtype));
     }
 
     return options;
@@ -138,9 +215,35 @@ Torch_IValue Torch_ConvertIValueToTorchIValue(torch::IValue value) {
             .itype = Torch_IValueTypeTuple,
             .data_ptr = tuple,
         };
+    } else if (value.isInt()) {
+        return Torch_IValue{
+            .itype = Torch_IValueTypeInt,
+            .data_ptr = NULL,
+            .int_value = value.toInt(),
+        };
+    } else if (value.isDouble()) {
+        return Torch_IValue{
+            .itype = Torch_IValueTypeDouble,
+            .data_ptr = NULL,
+            .int_value = 0,
+            .double_value = value.toDouble(),
+        };
+    } else if (value.isBool()) {
+        return Torch_IValue{
+            .itype = Torch_IValueTypeBool,
+            .data_ptr = NULL,
+            .int_value = value.toBool(),
+        };
+    } else if (value.isNone()) {
+        return Torch_IValue{
+            .itype = Torch_IValueTypeNone,
+        };
     }
 
-    return Torch_IValue{};
+    return Torch_IValue{
+        .itype = Torch_IValueTypeUnsupported,
+        .data_ptr = Torch_CopyString(value.tagKind()),
+    };
 }
 
 torch::IValue Torch_ConvertTorchIValueToIValue(Torch_IValue value) {
@@ -158,13 +261,34 @@ torch::IValue Torch_ConvertTorchIValueToIValue(Torch_IValue value) {
         }
 
         return torch::jit::Tuple::create(std::move(values));
+    } else if (value.itype == Torch_IValueTypeInt) {
+        return torch::IValue(value.int_value);
+    } else if (value.itype == Torch_IValueTypeDouble) {
+        return torch::IValue(value.double_value);
+    } else if (value.itype == Torch_IValueTypeBool) {
+        return torch::IValue(value.int_value != 0);
+    } else if (value.itype == Torch_IValueTypeNone) {
+        return torch::IValue();
     }
 
     // TODO handle this case
     return 0;
 }
 
-Torch_TensorContext Torch_NewTensor(void* input_data, int64_t* dimensions, int n_dim, Torch_DataType dtype) {
+std::vector<torch::Tensor> Torch_ConvertTensorContexts(Torch_TensorContext* tensors, size_t size) {
+    std::vector<torch::Tensor> result;
+    result.reserve(size);
+
+    for (int i = 0; i < size; i++) {
+        auto tensor = (Torch_Tensor*)*(tensors+i);
+        result.push_back(tensor->tensor);
+    }
+
+    return result;
+}
+
+Torch_TensorContext Torch_NewTensor(void* input_data, int64_t* dimensions, int n_dim, Torch_DataType dtype, Torch_Error* error) {
+    HANDLE_TH_ERRORS
     torch::TensorOptions options = Torch_ConvertDataTypeToOptions(dtype);
     std::vector<int64_t> sizes;
     sizes.assign(dimensions, dimensions + n_dim);
@@ -175,32 +299,137 @@ Torch_TensorContext Torch_NewTensor(void* input_data, int64_t* dimensions, int n
     tensor->tensor = ten;
 
     return (void *)tensor;
+    END_HANDLE_TH_ERRORS(error, nullptr)
 }
 
-void* Torch_TensorValue(Torch_TensorContext ctx) {
+// Torch_MappedFile is memory mapped by Go (see mapFile). Every tensor created over the mapping holds a
+// reference in its storage deleter so that the file stays mapped as long as any storage uses it, even
+// if the storage outlives the Go tensors (e.g. views returned by methods).
+struct Torch_MappedFile {
+    void* data;
+    size_t size;
+
+    ~Torch_MappedFile() {
+#if defined(__linux__) || defined(__APPLE__)
+        munmap(data, size);
+#endif
+    }
+};
+
+Torch_MappedFileContext Torch_NewMappedFile(void* data, size_t size) {
+    return new std::shared_ptr<Torch_MappedFile>(new Torch_MappedFile{data, size});
+}
+
+void Torch_DeleteMappedFile(Torch_MappedFileContext ctx) {
+    delete (std::shared_ptr<Torch_MappedFile>*)ctx;
+}
+
+Torch_TensorContext Torch_NewMappedTensor(Torch_MappedFileContext ctx, void* input_data, int64_t* dimensions, int n_dim, Torch_DataType dtype, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto mapping = *(std::shared_ptr<Torch_MappedFile>*)ctx;
+    torch::TensorOptions options = Torch_ConvertDataTypeToOptions(dtype);
+    std::vector<int64_t> sizes;
+    sizes.assign(dimensions, dimensions + n_dim);
+
+    torch::Tensor ten = torch::from_blob(input_data, torch::IntList(sizes), [mapping](void*) mutable { mapping.reset(); }, options);
+
+    auto tensor = new Torch_Tensor();
+    tensor->tensor = ten;
+
+    return (void *)tensor;
+    END_HANDLE_TH_ERRORS(error, nullptr)
+}
+
+void* Torch_TensorValue(Torch_TensorContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
     auto tensor = (Torch_Tensor*)ctx;
     return tensor->tensor.data_ptr();
+    END_HANDLE_TH_ERRORS(error, nullptr)
 }
 
-Torch_DataType Torch_TensorType(Torch_TensorContext ctx) {
+void Torch_TensorCopyData(Torch_TensorContext ctx, void* dst, size_t nbytes, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = (Torch_Tensor*)ctx;
+    auto contiguous = tensor->tensor.contiguous();
+    if (contiguous.numel() * contiguous.dtype().itemsize() != nbytes) {
+        throw std::invalid_argument("destination size does not match tensor size");
+    }
+    memcpy(dst, contiguous.data_ptr(), nbytes);
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_TensorGatherData(Torch_TensorContext ctx, int64_t* indices, size_t n, void* dst, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = (Torch_Tensor*)ctx;
+    auto sizes = tensor->tensor.sizes();
+    auto strides = tensor->tensor.strides();
+    auto itemsize = tensor->tensor.dtype().itemsize();
+    auto numel = tensor->tensor.numel();
+    auto data = (char*)tensor->tensor.data_ptr();
+
+    for (size_t i = 0; i < n; i++) {
+        if (indices[i] < 0 || indices[i] >= numel) {
+            throw std::out_of_range("element index out of range");
+        }
+
+        // Elements are indexed in C order so the index is mapped to an offset with the strides
+        int64_t rest = indices[i];
+        int64_t offset = 0;
+        for (int64_t dim = (int64_t)sizes.size() - 1; dim >= 0; dim--) {
+            offset += (rest % sizes[dim]) * strides[dim];
+            rest /= sizes[dim];
+        }
+
+        memcpy((char*)dst + i * itemsize, data + offset * itemsize, itemsize);
+    }
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+Torch_DataType Torch_TensorType(Torch_TensorContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
     auto tensor = (Torch_Tensor*)ctx;
     auto type = tensor->tensor.scalar_type();
     return Torch_ConvertScalarTypeToDataType(type);
+    END_HANDLE_TH_ERRORS(error, Torch_Unknown)
 }
 
-int64_t* Torch_TensorShape(Torch_TensorContext ctx, size_t* dims){
+int64_t* Torch_TensorShape(Torch_TensorContext ctx, size_t* dims, Torch_Error* error) {
+    HANDLE_TH_ERRORS
     auto tensor = (Torch_Tensor*)ctx;
     auto sizes = tensor->tensor.sizes();
     *dims = sizes.size();
     return (int64_t*)sizes.data();
+    END_HANDLE_TH_ERRORS(error, nullptr)
 }
 
-void Torch_PrintTensors(Torch_TensorContext* tensors, size_t input_size) {
+int64_t* Torch_TensorStrides(Torch_TensorContext ctx, size_t* dims, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = (Torch_Tensor*)ctx;
+    auto strides = tensor->tensor.strides();
+    *dims = strides.size();
+    return (int64_t*)strides.data();
+    END_HANDLE_TH_ERRORS(error, nullptr)
+}
+
+void Torch_PrintTensors(Torch_TensorContext* tensors, size_t input_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
      for (int i = 0; i < input_size; i++) {
         auto ctx = tensors+i;
         auto tensor = (Torch_Tensor*)*ctx;
         std::cout << tensor->tensor << "\n";
     }
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_SetNumThreads(int num_threads, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    at::set_num_threads(num_threads);
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+int Torch_GetNumThreads() {
+    return at::get_num_threads();
 }
 
 void Torch_DeleteTensor(Torch_TensorContext ctx) {
@@ -209,6 +438,71 @@ void Torch_DeleteTensor(Torch_TensorContext ctx) {
 
 }
 
+Torch_TensorContext Torch_Stack(Torch_TensorContext* tensors, size_t size, int64_t dim, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = new Torch_Tensor();
+    tensor->tensor = torch::stack(Torch_ConvertTensorContexts(tensors, size), dim);
+
+    return (void *)tensor;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_TensorContext* Torch_Unbind(Torch_TensorContext ctx, int64_t dim, size_t* res_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = (Torch_Tensor*)ctx;
+    auto tensors = torch::unbind(tensor->tensor, dim);
+
+    auto result = (Torch_TensorContext*)malloc(sizeof(Torch_TensorContext) * tensors.size());
+    *res_size = tensors.size();
+
+    for (std::vector<torch::Tensor>::size_type i = 0; i != tensors.size(); i++) {
+        auto t = new Torch_Tensor();
+        t->tensor = tensors[i];
+        *(result + i) = t;
+    }
+
+    return result;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+void Torch_TensorSetRequiresGrad(Torch_TensorContext ctx, int requires_grad, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = (Torch_Tensor*)ctx;
+    tensor->tensor.set_requires_grad(requires_grad != 0);
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+int Torch_TensorRequiresGrad(Torch_TensorContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
+    auto tensor = (Torch_Tensor*)ctx;
+    return tensor->tensor.requires_grad() ? 1 : 0;
+    END_HANDLE_TH_ERRORS(error, 0)
+}
+
+Torch_TensorContext Torch_TensorGrad(Torch_TensorContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
+    auto tensor = (Torch_Tensor*)ctx;
+    auto grad = tensor->tensor.grad();
+    if (!grad.defined()) {
+        return NULL;
+    }
+
+    auto result = new Torch_Tensor();
+    result->tensor = grad;
+
+    return (void *)result;
+    END_HANDLE_TH_ERRORS(error, nullptr)
+}
+
+void Torch_TensorBackward(Torch_TensorContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto tensor = (Torch_Tensor*)ctx;
+    tensor->tensor.backward();
+    END_HANDLE_TH_ERRORS(error,)
+}
+
 Torch_JITModuleContext Torch_CompileTorchScript(char* cstring_script, Torch_Error* error) {
     HANDLE_TH_ERRORS
     std::string script(cstring_script);
@@ -237,6 +531,22 @@ void Torch_ExportJITModule(Torch_JITModuleContext ctx, char* cstring_path, Torch
     END_HANDLE_TH_ERRORS(error,)
 }
 
+Torch_JITModuleContext Torch_JITModuleClone(Torch_JITModuleContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto mod = (Torch_JITModule*)ctx;
+
+    // Serializing and loading the module copies the parameters, buffers and code
+    std::stringstream stream;
+    mod->module->save(stream);
+    stream.seekg(0);
+
+    auto clone = new Torch_JITModule();
+    clone->module = torch::jit::load(stream);
+
+    return (void *)clone;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
 Torch_JITModuleMethodContext Torch_JITModuleGetMethod(Torch_JITModuleContext ctx, char* cstring_method, Torch_Error* error) {
     HANDLE_TH_ERRORS
     std::string method_name(cstring_method);
@@ -251,7 +561,9 @@ Torch_JITModuleMethodContext Torch_JITModuleGetMethod(Torch_JITModuleContext ctx
 }
 
 
-char** Torch_JITModuleGetMethodNames(Torch_JITModuleContext ctx, size_t* len) {
+char** Torch_JITModuleGetMethodNames(Torch_JITModuleContext ctx, size_t* len, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
     auto mod = (Torch_JITModule*)ctx;
     auto size = mod->module->get_methods().size();
     *len = size;
@@ -269,6 +581,7 @@ char** Torch_JITModuleGetMethodNames(Torch_JITModuleContext ctx, size_t* len) {
     }
 
     return result;
+    END_HANDLE_TH_ERRORS(error, nullptr)
 }
 
 Torch_IValue Torch_JITModuleMethodRun(Torch_JITModuleMethodContext ctx, Torch_IValue* inputs, size_t input_size, Torch_Error* error) {
@@ -287,8 +600,60 @@ Torch_IValue Torch_JITModuleMethodRun(Torch_JITModuleMethodContext ctx, Torch_IV
     END_HANDLE_TH_ERRORS(error, Torch_IValue{})
 }
 
+Torch_IValue Torch_JITModuleMethodRunKwargs(Torch_JITModuleMethodContext ctx, Torch_IValue* inputs, size_t input_size, char** kwarg_names, Torch_IValue* kwarg_values, size_t kwarg_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto met = (Torch_JITModule_Method*)ctx;
+    auto schema = met->run.getSchema();
+    auto arguments = schema.arguments();
+
+    // self is bound by the module and kwarg-only arguments cannot be given positionally
+    size_t offset = (!arguments.empty() && arguments[0].name() == "self") ? 1 : 0;
+    size_t positional = offset;
+    while (positional < arguments.size() && !arguments[positional].kwarg_only()) {
+        positional++;
+    }
+    if (input_size > positional - offset) {
+        throw std::runtime_error("too many positional arguments for " + schema.name());
+    }
+
+    std::unordered_map<std::string, torch::IValue> kwargs;
+    for (int i = 0; i < kwarg_size; i++) {
+        kwargs.emplace(std::string(kwarg_names[i]), Torch_ConvertTorchIValueToIValue(*(kwarg_values + i)));
+    }
+
+    std::vector<torch::IValue> inputs_vec;
+    for (int i = 0; i < input_size; i++) {
+        inputs_vec.push_back(Torch_ConvertTorchIValueToIValue(*(inputs + i)));
+    }
+
+    // Remaining arguments are taken from kwargs or filled with schema defaults
+    size_t used = 0;
+    for (size_t i = offset + input_size; i < arguments.size(); i++) {
+        auto& arg = arguments[i];
+        auto kwarg = kwargs.find(arg.name());
+        if (kwarg != kwargs.end()) {
+            inputs_vec.push_back(kwarg->second);
+            used++;
+        } else if (arg.default_value()) {
+            inputs_vec.push_back(*arg.default_value());
+        } else {
+            throw std::runtime_error("missing argument " + arg.name() + " for " + schema.name());
+        }
+    }
 
-Torch_ModuleMethodArgument* Torch_JITModuleMethodArguments(Torch_JITModuleMethodContext ctx, size_t* res_size) {
+    if (used != kwargs.size()) {
+        throw std::runtime_error("unexpected keyword arguments for " + schema.name());
+    }
+
+    auto res = met->run(inputs_vec);
+    return Torch_ConvertIValueToTorchIValue(res);
+    END_HANDLE_TH_ERRORS(error, Torch_IValue{})
+}
+
+
+Torch_ModuleMethodArgument* Torch_JITModuleMethodArguments(Torch_JITModuleMethodContext ctx, size_t* res_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
     auto met = (Torch_JITModule_Method*)ctx;
     auto schema = met->run.getSchema();
     auto arguments = schema.arguments();
@@ -305,17 +670,25 @@ Torch_ModuleMethodArgument* Torch_JITModuleMethodArguments(Torch_JITModuleMethod
         char *cstr_type = new char[type.length() + 1];
         strcpy(cstr_type, type.c_str());
 
+        auto default_value = arguments[i].default_value();
+
         *(result + i) = Torch_ModuleMethodArgument{
             .name = cstr_name,
             .typ = cstr_type,
+            .has_default = default_value.has_value(),
+            .default_value = default_value ? Torch_ConvertIValueToTorchIValue(*default_value) : Torch_IValue{},
+            .kwarg_only = arguments[i].kwarg_only(),
         };
     }
 
     return result;
+    END_HANDLE_TH_ERRORS(error, nullptr)
 }
 
 
-Torch_ModuleMethodArgument* Torch_JITModuleMethodReturns(Torch_JITModuleMethodContext ctx, size_t* res_size) {
+Torch_ModuleMethodArgument* Torch_JITModuleMethodReturns(Torch_JITModuleMethodContext ctx, size_t* res_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
     auto met = (Torch_JITModule_Method*)ctx;
     auto schema = met->run.getSchema();
     auto arguments = schema.returns();
@@ -340,8 +713,94 @@ Torch_ModuleMethodArgument* Torch_JITModuleMethodReturns(Torch_JITModuleMethodCo
     }
 
     return result;
+    END_HANDLE_TH_ERRORS(error, nullptr)
+}
+
+
+char* Torch_JITModuleMethodSchema(Torch_JITModuleMethodContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto met = (Torch_JITModule_Method*)ctx;
+
+    std::stringstream ss;
+    ss << met->run.getSchema();
+
+    auto schema = ss.str();
+    char *cstr_schema = (char*)malloc(schema.length() + 1);
+    strcpy(cstr_schema, schema.c_str());
+
+    return cstr_schema;
+    END_HANDLE_TH_ERRORS(error, nullptr)
+}
+
+
+at::Tensor* Torch_JITModuleFindParameter(std::shared_ptr<torch::jit::script::Module> module, const std::string& name) {
+    auto pos = name.find('.');
+    if (pos == std::string::npos) {
+        auto param = module->find_parameter(name);
+        return param == nullptr ? nullptr : param->slot();
+    }
+
+    auto submodule = module->find_module(name.substr(0, pos));
+    if (submodule == nullptr) {
+        return nullptr;
+    }
+
+    return Torch_JITModuleFindParameter(submodule->module, name.substr(pos + 1));
+}
+
+void Torch_JITModuleCollectParameters(std::shared_ptr<torch::jit::script::Module> module, const std::string& prefix, std::vector<std::pair<std::string, torch::Tensor>>& result) {
+    for (auto& param : module->get_parameters()) {
+        result.emplace_back(prefix + param.key(), param.value().slot()->detach());
+    }
+
+    for (auto& submodule : module->get_modules()) {
+        Torch_JITModuleCollectParameters(submodule.value().module, prefix + submodule.key() + ".", result);
+    }
+}
+
+Torch_NamedTensor* Torch_JITModuleNamedParameters(Torch_JITModuleContext ctx, size_t* res_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto mod = (Torch_JITModule*)ctx;
+
+    std::vector<std::pair<std::string, torch::Tensor>> parameters;
+    Torch_JITModuleCollectParameters(mod->module, "", parameters);
+
+    auto result = (Torch_NamedTensor*)malloc(sizeof(Torch_NamedTensor) * parameters.size());
+    *res_size = parameters.size();
+
+    for (std::vector<torch::Tensor>::size_type i = 0; i != parameters.size(); i++) {
+        auto tensor = new Torch_Tensor();
+        tensor->tensor = parameters[i].second;
+
+        *(result + i) = Torch_NamedTensor{
+            .name = Torch_CopyString(parameters[i].first),
+            .tensor = tensor,
+        };
+    }
+
+    return result;
+    END_HANDLE_TH_ERRORS(error, NULL)
 }
 
+void Torch_JITModuleSetParameter(Torch_JITModuleContext ctx, char* cstring_name, Torch_TensorContext value_ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    std::string name(cstring_name);
+    auto mod = (Torch_JITModule*)ctx;
+    auto value = ((Torch_Tensor*)value_ctx)->tensor;
+
+    auto param = Torch_JITModuleFindParameter(mod->module, name);
+    if (param == nullptr) {
+        throw std::invalid_argument("parameter '" + name + "' is not defined");
+    }
+
+    if (param->sizes() != value.sizes()) {
+        throw std::invalid_argument("size mismatch for parameter '" + name + "'");
+    }
+
+    torch::NoGradGuard no_grad;
+    param->copy_(value);
+    END_HANDLE_TH_ERRORS(error,)
+}
 
 void Torch_DeleteJITModuleMethod(Torch_JITModuleMethodContext ctx) {
     auto med = (Torch_JITModule_Method*)ctx;
@@ -352,3 +811,311 @@ void Torch_DeleteJITModule(Torch_JITModuleContext ctx) {
     auto mod = (Torch_JITModule*)ctx;
     delete mod;
 }
+
+// Torch_NestedArchive returns the archive for a dotted path (e.g. "layer1.conv") creating the
+// archives of the path as needed so that archives have the structure of module state
+torch::serialize::OutputArchive& Torch_NestedArchive(torch::serialize::OutputArchive& root, std::unordered_map<std::string, std::shared_ptr<torch::serialize::OutputArchive>>& archives, const std::string& path) {
+    if (path.empty()) {
+        return root;
+    }
+
+    auto it = archives.find(path);
+    if (it != archives.end()) {
+        return *it->second;
+    }
+
+    auto pos = path.rfind('.');
+    auto& parent = Torch_NestedArchive(root, archives, pos == std::string::npos ? "" : path.substr(0, pos));
+
+    auto archive = std::make_shared<torch::serialize::OutputArchive>();
+    parent.write(pos == std::string::npos ? path : path.substr(pos + 1), *archive);
+    archives[path] = archive;
+
+    return *archive;
+}
+
+// Torch_SaveModuleArchive saves tensors as the parameters of a module archive loadable with
+// torch::jit::load
+void Torch_SaveModuleArchive(char* cstring_path, Torch_NamedTensor* tensors, size_t size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    torch::serialize::OutputArchive archive;
+    std::unordered_map<std::string, std::shared_ptr<torch::serialize::OutputArchive>> archives;
+    for (int i = 0; i < size; i++) {
+        auto named = *(tensors+i);
+        std::string name(named.name);
+
+        // Dotted names are saved in nested archives as parameters of submodules
+        auto pos = name.rfind('.');
+        auto& parent = Torch_NestedArchive(archive, archives, pos == std::string::npos ? "" : name.substr(0, pos));
+        parent.write(pos == std::string::npos ? name : name.substr(pos + 1), ((Torch_Tensor*)named.tensor)->tensor.detach());
+    }
+
+    std::ofstream out(cstring_path, std::ios::out | std::ios::binary);
+    if (!out) {
+        throw std::runtime_error("open file failed: " + std::string(cstring_path));
+    }
+    archive.save_to(out);
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+Torch_TensorContext Torch_Loss(Torch_LossType loss, Torch_TensorContext input_ctx, Torch_TensorContext target_ctx, int reduction, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto input = ((Torch_Tensor*)input_ctx)->tensor;
+    auto target = ((Torch_Tensor*)target_ctx)->tensor;
+
+    torch::Tensor output;
+    switch (loss) {
+        case Torch_LossCrossEntropy:
+        output = torch::nll_loss(torch::log_softmax(input, 1), target, {}, reduction);
+        break;
+        case Torch_LossNLL:
+        output = torch::nll_loss(input, target, {}, reduction);
+        break;
+        case Torch_LossMSE:
+        output = torch::mse_loss(input, target, reduction);
+        break;
+        case Torch_LossL1:
+        output = torch::l1_loss(input, target, reduction);
+        break;
+        case Torch_LossSmoothL1:
+        output = torch::smooth_l1_loss(input, target, reduction);
+        break;
+        case Torch_LossBCEWithLogits:
+        output = torch::binary_cross_entropy_with_logits(input, target, {}, {}, reduction);
+        break;
+        case Torch_LossKLDiv:
+        output = torch::kl_div(input, target, reduction);
+        break;
+        default:
+        throw std::invalid_argument("unsupported loss type");
+    }
+
+    auto tensor = new Torch_Tensor();
+    tensor->tensor = output;
+
+    return (void *)tensor;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+template <typename ModuleHolder>
+Torch_NNModuleContext Torch_NewNNModule(ModuleHolder holder) {
+    auto mod = new Torch_NNModule();
+    mod->module = holder.ptr();
+    mod->forward = [holder](torch::Tensor input) mutable { return holder->forward(input); };
+
+    return (void *)mod;
+}
+
+Torch_NNModuleContext Torch_NNLinear(int64_t in_features, int64_t out_features, int bias, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    return Torch_NewNNModule(torch::nn::Linear(
+        torch::nn::LinearOptions(in_features, out_features).with_bias(bias != 0)
+    ));
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_NNModuleContext Torch_NNConv2d(int64_t in_channels, int64_t out_channels, int64_t kernel_size, int64_t stride, int64_t padding, int bias, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    return Torch_NewNNModule(torch::nn::Conv2d(
+        torch::nn::Conv2dOptions(in_channels, out_channels, kernel_size)
+            .stride(stride)
+            .padding(padding)
+            .with_bias(bias != 0)
+    ));
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_NNModuleContext Torch_NNBatchNorm2d(int64_t num_features, double eps, double momentum, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    return Torch_NewNNModule(torch::nn::BatchNorm(
+        torch::nn::BatchNormOptions(num_features)
+            .eps(eps)
+            .momentum(momentum)
+            .stateful(true)
+    ));
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_NNModuleContext Torch_NNEmbedding(int64_t num_embeddings, int64_t embedding_dim, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    return Torch_NewNNModule(torch::nn::Embedding(num_embeddings, embedding_dim));
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_NNModuleContext Torch_NNLayerNorm(int64_t* normalized_shape, int n_dim, double eps, int affine, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    std::vector<int64_t> shape;
+    shape.assign(normalized_shape, normalized_shape + n_dim);
+
+    auto layer_norm = std::make_shared<Torch_LayerNormImpl>(shape, eps, affine != 0);
+
+    auto mod = new Torch_NNModule();
+    mod->module = layer_norm;
+    mod->forward = [layer_norm](torch::Tensor input) { return layer_norm->forward(input); };
+
+    return (void *)mod;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_NNModuleContext Torch_NNDropout(double p, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    return Torch_NewNNModule(torch::nn::Dropout(p));
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_TensorContext Torch_NNModuleForward(Torch_NNModuleContext ctx, Torch_TensorContext input, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto mod = (Torch_NNModule*)ctx;
+
+    auto tensor = new Torch_Tensor();
+    tensor->tensor = mod->forward(((Torch_Tensor*)input)->tensor);
+
+    return (void *)tensor;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_NamedTensor* Torch_NNModuleNamedParameters(Torch_NNModuleContext ctx, size_t* res_size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
+    auto mod = (Torch_NNModule*)ctx;
+    auto parameters = mod->module->named_parameters();
+
+    auto result = (Torch_NamedTensor*)malloc(sizeof(Torch_NamedTensor) * parameters.size());
+    *res_size = parameters.size();
+
+    int i = 0;
+    for (auto& parameter : parameters) {
+        auto tensor = new Torch_Tensor();
+        tensor->tensor = parameter.value();
+
+        *(result + i) = Torch_NamedTensor{
+            .name = Torch_CopyString(parameter.key()),
+            .tensor = tensor,
+        };
+
+        i++;
+    }
+
+    return result;
+    END_HANDLE_TH_ERRORS(error, nullptr)
+}
+
+void Torch_NNModuleTrain(Torch_NNModuleContext ctx, int on, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
+    auto mod = (Torch_NNModule*)ctx;
+    mod->module->train(on != 0);
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_DeleteNNModule(Torch_NNModuleContext ctx) {
+    auto mod = (Torch_NNModule*)ctx;
+    delete mod;
+}
+
+Torch_OptimizerContext Torch_SGD(Torch_TensorContext* params, size_t params_size, double lr, double momentum, double dampening, double weight_decay, int nesterov, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto options = torch::optim::SGDOptions(lr)
+        .momentum(momentum)
+        .dampening(dampening)
+        .weight_decay(weight_decay)
+        .nesterov(nesterov != 0);
+
+    auto sgd = std::make_shared<torch::optim::SGD>(Torch_ConvertTensorContexts(params, params_size), options);
+
+    auto opt = new Torch_Optimizer();
+    opt->optimizer = sgd;
+    opt->get_lr = [sgd]() { return sgd->options.learning_rate(); };
+    opt->set_lr = [sgd](double lr) { sgd->options.learning_rate(lr); };
+
+    return (void *)opt;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+Torch_OptimizerContext Torch_Adam(Torch_TensorContext* params, size_t params_size, double lr, double beta1, double beta2, double weight_decay, double eps, int amsgrad, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto options = torch::optim::AdamOptions(lr)
+        .beta1(beta1)
+        .beta2(beta2)
+        .weight_decay(weight_decay)
+        .eps(eps)
+        .amsgrad(amsgrad != 0);
+
+    auto adam = std::make_shared<torch::optim::Adam>(Torch_ConvertTensorContexts(params, params_size), options);
+
+    auto opt = new Torch_Optimizer();
+    opt->optimizer = adam;
+    opt->get_lr = [adam]() { return adam->options.learning_rate(); };
+    opt->set_lr = [adam](double lr) { adam->options.learning_rate(lr); };
+
+    return (void *)opt;
+    END_HANDLE_TH_ERRORS(error, NULL)
+}
+
+void Torch_OptimizerStep(Torch_OptimizerContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto opt = (Torch_Optimizer*)ctx;
+    opt->optimizer->step();
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_OptimizerZeroGrad(Torch_OptimizerContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
+    auto opt = (Torch_Optimizer*)ctx;
+    opt->optimizer->zero_grad();
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+double Torch_OptimizerLearningRate(Torch_OptimizerContext ctx, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    Torch_CheckFailpoint(__func__);
+    auto opt = (Torch_Optimizer*)ctx;
+    return opt->get_lr();
+    END_HANDLE_TH_ERRORS(error, 0)
+}
+
+void Torch_OptimizerSetLearningRate(Torch_OptimizerContext ctx, double lr, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto opt = (Torch_Optimizer*)ctx;
+    opt->set_lr(lr);
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_OptimizerSave(Torch_OptimizerContext ctx, char* cstring_path, char** keys, double* values, size_t size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto opt = (Torch_Optimizer*)ctx;
+
+    torch::serialize::OutputArchive archive;
+    opt->optimizer->save(archive);
+
+    for (int i = 0; i < size; i++) {
+        std::string key(*(keys+i));
+        archive.write(key, torch::full({1}, *(values+i), torch::TensorOptions(torch::kDouble)));
+    }
+
+    archive.save_to(std::string(cstring_path));
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_OptimizerLoad(Torch_OptimizerContext ctx, char* cstring_path, char** keys, double* values, size_t size, Torch_Error* error) {
+    HANDLE_TH_ERRORS
+    auto opt = (Torch_Optimizer*)ctx;
+
+    torch::serialize::InputArchive archive;
+    archive.load_from(std::string(cstring_path));
+    opt->optimizer->load(archive);
+
+    for (int i = 0; i < size; i++) {
+        std::string key(*(keys+i));
+        torch::Tensor value;
+        archive.read(key, value);
+        *(values+i) = value.item<double>();
+    }
+    END_HANDLE_TH_ERRORS(error,)
+}
+
+void Torch_DeleteOptimizer(Torch_OptimizerContext ctx) {
+    auto opt = (Torch_Optimizer*)ctx;
+    delete opt;
+}
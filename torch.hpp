@@ -8,6 +8,9 @@ extern "C" {
     typedef void* Torch_TensorContext;
     typedef void* Torch_JITModuleContext;
     typedef void* Torch_JITModuleMethodContext;
+    typedef void* Torch_OptimizerContext;
+    typedef void* Torch_NNModuleContext;
+    typedef void* Torch_MappedFileContext;
 
     typedef enum Torch_DataType {
         Torch_Unknown = 0,
@@ -25,11 +28,30 @@ extern "C" {
     typedef enum Torch_IValueType {
         Torch_IValueTypeTensor = 1,
         Torch_IValueTypeTuple = 2,
+        Torch_IValueTypeInt = 3,
+        Torch_IValueTypeDouble = 4,
+        Torch_IValueTypeBool = 5,
+        Torch_IValueTypeNone = 6,
+        // Values without a Go representation (data_ptr is the name of the value type)
+        Torch_IValueTypeUnsupported = 7,
     } Torch_IValueType;
 
+    typedef enum Torch_LossType {
+        Torch_LossCrossEntropy = 1,
+        Torch_LossNLL = 2,
+        Torch_LossMSE = 3,
+        Torch_LossL1 = 4,
+        Torch_LossSmoothL1 = 5,
+        Torch_LossBCEWithLogits = 6,
+        Torch_LossKLDiv = 7,
+    } Torch_LossType;
+
     typedef struct Torch_IValue {
         Torch_IValueType itype;
         void* data_ptr;
+        // Scalar values (bools are stored in int_value)
+        int64_t int_value;
+        double double_value;
     } Torch_IValue;
 
     typedef struct Torch_IValueTuple {
@@ -40,32 +62,98 @@ extern "C" {
     typedef struct Torch_ModuleMethodArgument {
         char* name;
         char* typ;
-        //Torch_TensorContext default_value;
-        //Torch_DataType type;
+        int has_default;
+        Torch_IValue default_value;
+        int kwarg_only;
     } Torch_ModuleMethodArgument;
 
+    typedef struct Torch_NamedTensor {
+        char* name;
+        Torch_TensorContext tensor;
+    } Torch_NamedTensor;
+
     typedef struct Torch_Error {
         char* message;
+        char* backtrace;
     } Torch_Error;
 
-    void Torch_PrintTensors(Torch_TensorContext* tensors, size_t input_size);
+    void Torch_PrintTensors(Torch_TensorContext* tensors, size_t input_size, Torch_Error* error);
+
+    // Failure injection for tests
+    void Torch_SetFailpoint(char* name);
+
+    // Parallelism
+    void Torch_SetNumThreads(int num_threads, Torch_Error* error);
+    int Torch_GetNumThreads();
 
     // Tensor
-    Torch_TensorContext Torch_NewTensor(void* data, int64_t* dimensions, int n_dim, Torch_DataType dtype);
-    void* Torch_TensorValue(Torch_TensorContext ctx);
-    Torch_DataType Torch_TensorType(Torch_TensorContext ctx);
-    int64_t* Torch_TensorShape(Torch_TensorContext ctx, size_t* dims);
+    Torch_TensorContext Torch_NewTensor(void* data, int64_t* dimensions, int n_dim, Torch_DataType dtype, Torch_Error* error);
+    Torch_TensorContext Torch_NewMappedTensor(Torch_MappedFileContext ctx, void* data, int64_t* dimensions, int n_dim, Torch_DataType dtype, Torch_Error* error);
+    void* Torch_TensorValue(Torch_TensorContext ctx, Torch_Error* error);
+    void Torch_TensorCopyData(Torch_TensorContext ctx, void* dst, size_t nbytes, Torch_Error* error);
+    void Torch_TensorGatherData(Torch_TensorContext ctx, int64_t* indices, size_t n, void* dst, Torch_Error* error);
+    Torch_DataType Torch_TensorType(Torch_TensorContext ctx, Torch_Error* error);
+    int64_t* Torch_TensorShape(Torch_TensorContext ctx, size_t* dims, Torch_Error* error);
+    int64_t* Torch_TensorStrides(Torch_TensorContext ctx, size_t* dims, Torch_Error* error);
     void Torch_DeleteTensor(Torch_TensorContext ctx);
 
+    // Tensor operations
+    Torch_TensorContext Torch_Stack(Torch_TensorContext* tensors, size_t size, int64_t dim, Torch_Error* error);
+    Torch_TensorContext* Torch_Unbind(Torch_TensorContext ctx, int64_t dim, size_t* res_size, Torch_Error* error);
+
+    // Autograd
+    void Torch_TensorSetRequiresGrad(Torch_TensorContext ctx, int requires_grad, Torch_Error* error);
+    int Torch_TensorRequiresGrad(Torch_TensorContext ctx, Torch_Error* error);
+    Torch_TensorContext Torch_TensorGrad(Torch_TensorContext ctx, Torch_Error* error);
+    void Torch_TensorBackward(Torch_TensorContext ctx, Torch_Error* error);
+
+    // Memory mapped files (unmapped when the file and all tensors created over it are deleted)
+    Torch_MappedFileContext Torch_NewMappedFile(void* data, size_t size);
+    void Torch_DeleteMappedFile(Torch_MappedFileContext ctx);
+
+    // Serialization
+    void Torch_SaveModuleArchive(char* path, Torch_NamedTensor* tensors, size_t size, Torch_Error* error);
+
+    // Losses
+    Torch_TensorContext Torch_Loss(Torch_LossType loss, Torch_TensorContext input, Torch_TensorContext target, int reduction, Torch_Error* error);
+
+    // NN modules
+    Torch_NNModuleContext Torch_NNLinear(int64_t in_features, int64_t out_features, int bias, Torch_Error* error);
+    Torch_NNModuleContext Torch_NNConv2d(int64_t in_channels, int64_t out_channels, int64_t kernel_size, int64_t stride, int64_t padding, int bias, Torch_Error* error);
+    Torch_NNModuleContext Torch_NNBatchNorm2d(int64_t num_features, double eps, double momentum, Torch_Error* error);
+    Torch_NNModuleContext Torch_NNEmbedding(int64_t num_embeddings, int64_t embedding_dim, Torch_Error* error);
+    Torch_NNModuleContext Torch_NNLayerNorm(int64_t* normalized_shape, int n_dim, double eps, int affine, Torch_Error* error);
+    Torch_NNModuleContext Torch_NNDropout(double p, Torch_Error* error);
+    Torch_TensorContext Torch_NNModuleForward(Torch_NNModuleContext ctx, Torch_TensorContext input, Torch_Error* error);
+    Torch_NamedTensor* Torch_NNModuleNamedParameters(Torch_NNModuleContext ctx, size_t* res_size, Torch_Error* error);
+    void Torch_NNModuleTrain(Torch_NNModuleContext ctx, int on, Torch_Error* error);
+    void Torch_DeleteNNModule(Torch_NNModuleContext ctx);
+
+    // Optimizers
+    Torch_OptimizerContext Torch_SGD(Torch_TensorContext* params, size_t params_size, double lr, double momentum, double dampening, double weight_decay, int nesterov, Torch_Error* error);
+    Torch_OptimizerContext Torch_Adam(Torch_TensorContext* params, size_t params_size, double lr, double beta1, double beta2, double weight_decay, double eps, int amsgrad, Torch_Error* error);
+    void Torch_OptimizerStep(Torch_OptimizerContext ctx, Torch_Error* error);
+    void Torch_OptimizerZeroGrad(Torch_OptimizerContext ctx, Torch_Error* error);
+    double Torch_OptimizerLearningRate(Torch_OptimizerContext ctx, Torch_Error* error);
+    void Torch_OptimizerSetLearningRate(Torch_OptimizerContext ctx, double lr, Torch_Error* error);
+    void Torch_OptimizerSave(Torch_OptimizerContext ctx, char* path, char** keys, double* values, size_t size, Torch_Error* error);
+    void Torch_OptimizerLoad(Torch_OptimizerContext ctx, char* path, char** keys, double* values, size_t size, Torch_Error* error);
+    void Torch_DeleteOptimizer(Torch_OptimizerContext ctx);
+
     // JIT
     Torch_JITModuleContext Torch_CompileTorchScript(char* script, Torch_Error* error);
     Torch_JITModuleContext Torch_LoadJITModule(char* path, Torch_Error* error);
     void Torch_ExportJITModule(Torch_JITModuleContext ctx, char* path, Torch_Error* error);
+    Torch_JITModuleContext Torch_JITModuleClone(Torch_JITModuleContext ctx, Torch_Error* error);
     Torch_JITModuleMethodContext Torch_JITModuleGetMethod(Torch_JITModuleContext ctx, char* method, Torch_Error* error);
-    char** Torch_JITModuleGetMethodNames(Torch_JITModuleContext ctx, size_t* len);
+    char** Torch_JITModuleGetMethodNames(Torch_JITModuleContext ctx, size_t* len, Torch_Error* error);
     Torch_IValue Torch_JITModuleMethodRun(Torch_JITModuleMethodContext ctx, Torch_IValue* inputs, size_t input_size, Torch_Error* error);
-    Torch_ModuleMethodArgument* Torch_JITModuleMethodArguments(Torch_JITModuleMethodContext ctx, size_t* res_size);
-    Torch_ModuleMethodArgument* Torch_JITModuleMethodReturns(Torch_JITModuleMethodContext ctx, size_t* res_size);
+    Torch_IValue Torch_JITModuleMethodRunKwargs(Torch_JITModuleMethodContext ctx, Torch_IValue* inputs, size_t input_size, char** kwarg_names, Torch_IValue* kwarg_values, size_t kwarg_size, Torch_Error* error);
+    Torch_ModuleMethodArgument* Torch_JITModuleMethodArguments(Torch_JITModuleMethodContext ctx, size_t* res_size, Torch_Error* error);
+    Torch_ModuleMethodArgument* Torch_JITModuleMethodReturns(Torch_JITModuleMethodContext ctx, size_t* res_size, Torch_Error* error);
+    char* Torch_JITModuleMethodSchema(Torch_JITModuleMethodContext ctx, Torch_Error* error);
+    Torch_NamedTensor* Torch_JITModuleNamedParameters(Torch_JITModuleContext ctx, size_t* res_size, Torch_Error* error);
+    void Torch_JITModuleSetParameter(Torch_JITModuleContext ctx, char* name, Torch_TensorContext value, Torch_Error* error);
     void Torch_DeleteJITModuleMethod(Torch_JITModuleMethodContext ctx);
     void Torch_DeleteJITModule(Torch_JITModuleContext ctx);
 
@@ -0,0 +1,357 @@
+package torch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type is a TorchScript type of a method argument or return value
+type Type interface {
+	// String returns the type as a TorchScript annotation (e.g. List[int])
+	String() string
+}
+
+// TensorType is the type of tensors. Types recorded by tracing are annotated with the data type and
+// sizes of the example inputs (e.g. Float(2, 3)).
+type TensorType struct {
+	// DType is the annotated data type (zero if not annotated)
+	DType DType
+	// Sizes are the annotated sizes with -1 for unknown sizes (nil if the rank is not annotated)
+	Sizes []int64
+}
+
+func (t TensorType) String() string {
+	if t.DType == 0 && t.Sizes == nil {
+		return "Tensor"
+	}
+
+	sizes := make([]string, len(t.Sizes))
+	for i, size := range t.Sizes {
+		if size < 0 {
+			sizes[i] = "*"
+		} else {
+			sizes[i] = strconv.FormatInt(size, 10)
+		}
+	}
+	return dtypeName(t.DType) + "(" + strings.Join(sizes, ", ") + ")"
+}
+
+// IntType is the type of integers (int64 in Go)
+type IntType struct{}
+
+func (IntType) String() string { return "int" }
+
+// FloatType is the type of floating point numbers (float64 in Go)
+type FloatType struct{}
+
+func (FloatType) String() string { return "float" }
+
+// BoolType is the type of booleans
+type BoolType struct{}
+
+func (BoolType) String() string { return "bool" }
+
+// StringType is the type of strings
+type StringType struct{}
+
+func (StringType) String() string { return "str" }
+
+// NoneType is the type of None (nil in Go)
+type NoneType struct{}
+
+func (NoneType) String() string { return "None" }
+
+// ListType is the type of lists of Elem
+type ListType struct {
+	Elem Type
+}
+
+func (t ListType) String() string { return "List[" + t.Elem.String() + "]" }
+
+// DictType is the type of dictionaries from Key to Value
+type DictType struct {
+	Key   Type
+	Value Type
+}
+
+func (t DictType) String() string { return "Dict[" + t.Key.String() + ", " + t.Value.String() + "]" }
+
+// TupleType is the type of tuples with elements of types Elems
+type TupleType struct {
+	Elems []Type
+}
+
+func (t TupleType) String() string {
+	elems := make([]string, len(t.Elems))
+	for i, elem := range t.Elems {
+		elems[i] = elem.String()
+	}
+	return "Tuple[" + strings.Join(elems, ", ") + "]"
+}
+
+// OptionalType is the type of values which are either Elem or None
+type OptionalType struct {
+	Elem Type
+}
+
+func (t OptionalType) String() string { return "Optional[" + t.Elem.String() + "]" }
+
+// OtherType is any other type (e.g. Device or a class) identified by its name
+type OtherType struct {
+	Name string
+}
+
+func (t OtherType) String() string { return t.Name }
+
+// tensorTypes maps the scalar type names LibTorch uses in annotated tensor types to data types.
+// Types without a DType (e.g. Half) are parsed as tensors with any data type.
+var tensorTypes = map[string]DType{
+	"Tensor": 0,
+	"Byte":   Byte,
+	"Char":   Char,
+	"Short":  0,
+	"Int":    Int,
+	"Long":   Long,
+	"Half":   0,
+	"Float":  Float,
+	"Double": Double,
+	"Bool":   0,
+}
+
+func dtypeName(dt DType) string {
+	for name, tensorDType := range tensorTypes {
+		if tensorDType == dt && dt != 0 {
+			return name
+		}
+	}
+	return "Tensor"
+}
+
+var scalarTypes = map[string]Type{
+	"Tensor": TensorType{},
+	"int":    IntType{},
+	"float":  FloatType{},
+	"bool":   BoolType{},
+	"str":    StringType{},
+	"None":   NoneType{},
+}
+
+// ParseType parses a TorchScript type both in the form LibTorch prints schemas (int[], Tensor?,
+// (Tensor, Tensor), Dict(str, Tensor)) and as annotations (List[int], Optional[Tensor],
+// Tuple[Tensor, Tensor], Dict[str, Tensor])
+func ParseType(typ string) (Type, error) {
+	p := &typeParser{input: typ}
+	t, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at %d in type %s", p.input[p.pos:], p.pos, typ)
+	}
+
+	return t, nil
+}
+
+type typeParser struct {
+	input string
+	pos   int
+}
+
+func (p *typeParser) parse() (Type, error) {
+	p.skipSpaces()
+
+	var t Type
+	if p.consume("(") {
+		elems, err := p.parseList(")")
+		if err != nil {
+			return nil, err
+		}
+		t = TupleType{Elems: elems}
+	} else {
+		name := p.parseName()
+		if name == "" {
+			return nil, p.errorf("expected a type")
+		}
+
+		switch {
+		case (name == "List" || name == "Optional") && p.consume("["):
+			elems, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			if len(elems) != 1 {
+				return nil, p.errorf("%s expects a single type", name)
+			}
+			if name == "List" {
+				t = ListType{Elem: elems[0]}
+			} else {
+				t = OptionalType{Elem: elems[0]}
+			}
+		case name == "Tuple" && p.consume("["):
+			elems, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			t = TupleType{Elems: elems}
+		case name == "Dict" && (p.peek("[") || p.peek("(")):
+			closing := "]"
+			if p.consume("(") {
+				closing = ")"
+			} else {
+				p.consume("[")
+			}
+			elems, err := p.parseList(closing)
+			if err != nil {
+				return nil, err
+			}
+			if len(elems) != 2 {
+				return nil, p.errorf("Dict expects a key and a value type")
+			}
+			t = DictType{Key: elems[0], Value: elems[1]}
+		case p.peek("("):
+			dt, ok := tensorTypes[name]
+			if !ok {
+				return nil, p.errorf("unknown tensor type %s", name)
+			}
+			p.consume("(")
+			sizes, err := p.parseSizes()
+			if err != nil {
+				return nil, err
+			}
+			t = TensorType{DType: dt, Sizes: sizes}
+		default:
+			if scalar, ok := scalarTypes[name]; ok {
+				t = scalar
+			} else {
+				t = OtherType{Name: name}
+			}
+		}
+	}
+
+	// Postfix list (int[] or int[2]) and optional (Tensor?) markers
+	for {
+		if p.consume("[") {
+			for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+				p.pos++
+			}
+			if !p.consume("]") {
+				return nil, p.errorf("expected ]")
+			}
+			t = ListType{Elem: t}
+		} else if p.consume("?") {
+			t = OptionalType{Elem: t}
+		} else {
+			return t, nil
+		}
+	}
+}
+
+func (p *typeParser) parseList(closing string) ([]Type, error) {
+	var elems []Type
+
+	p.skipSpaces()
+	if p.consume(closing) {
+		return elems, nil
+	}
+
+	for {
+		elem, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+
+		p.skipSpaces()
+		if p.consume(closing) {
+			return elems, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or %s", closing)
+		}
+	}
+}
+
+// parseSizes parses the sizes of an annotated tensor type (e.g. 2, *, 3) skipping any
+// other properties (e.g. strides=[3, 1], device=cpu)
+func (p *typeParser) parseSizes() ([]int64, error) {
+	sizes := []int64{}
+	for {
+		p.skipSpaces()
+		if p.consume(")") {
+			return sizes, nil
+		}
+
+		switch {
+		case p.consume("*"):
+			sizes = append(sizes, -1)
+		case p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9':
+			start := p.pos
+			for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+				p.pos++
+			}
+			size, err := strconv.ParseInt(p.input[start:p.pos], 10, 64)
+			if err != nil {
+				return nil, p.errorf("invalid size: %v", err)
+			}
+			sizes = append(sizes, size)
+		default:
+			if p.parseName() == "" || !p.consume("=") {
+				return nil, p.errorf("expected a size")
+			}
+			depth := 0
+			for p.pos < len(p.input) && (depth > 0 || (p.input[p.pos] != ',' && p.input[p.pos] != ')')) {
+				switch p.input[p.pos] {
+				case '[', '(':
+					depth++
+				case ']', ')':
+					depth--
+				}
+				p.pos++
+			}
+		}
+
+		p.skipSpaces()
+		if !p.peek(")") && !p.consume(",") {
+			return nil, p.errorf("expected , or )")
+		}
+	}
+}
+
+func (p *typeParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *typeParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *typeParser) peek(s string) bool {
+	return strings.HasPrefix(p.input[p.pos:], s)
+}
+
+func (p *typeParser) consume(s string) bool {
+	p.skipSpaces()
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *typeParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid type %s at %d: %s", p.input, p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,72 @@
+package torch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseType(t *testing.T) {
+	cases := []struct {
+		typ      string
+		expected Type
+	}{
+		{"Tensor", TensorType{}},
+		{"int", IntType{}},
+		{"float", FloatType{}},
+		{"bool", BoolType{}},
+		{"str", StringType{}},
+		{"None", NoneType{}},
+		{"Device", OtherType{Name: "Device"}},
+		{"int[]", ListType{Elem: IntType{}}},
+		{"int[2]", ListType{Elem: IntType{}}},
+		{"List[int]", ListType{Elem: IntType{}}},
+		{"Tensor?", OptionalType{Elem: TensorType{}}},
+		{"Optional[Tensor]", OptionalType{Elem: TensorType{}}},
+		{"Tensor[]?", OptionalType{Elem: ListType{Elem: TensorType{}}}},
+		{"(Tensor, float)", TupleType{Elems: []Type{TensorType{}, FloatType{}}}},
+		{"Tuple[Tensor, float]", TupleType{Elems: []Type{TensorType{}, FloatType{}}}},
+		{"Tuple[]", TupleType{}},
+		{"Dict(str, Tensor)", DictType{Key: StringType{}, Value: TensorType{}}},
+		{"Dict[str, List[Tensor]]", DictType{Key: StringType{}, Value: ListType{Elem: TensorType{}}}},
+		{"Float(2, 3)", TensorType{DType: Float, Sizes: []int64{2, 3}}},
+		{"Long(*, *)", TensorType{DType: Long, Sizes: []int64{-1, -1}}},
+		{"Double()", TensorType{DType: Double, Sizes: []int64{}}},
+		{"Half(4)", TensorType{Sizes: []int64{4}}},
+		{
+			"Float(2, 3, strides=[3, 1], requires_grad=0, device=cpu)",
+			TensorType{DType: Float, Sizes: []int64{2, 3}},
+		},
+		{
+			"Tuple[(Tensor, int[])?, Dict[str, Optional[float]]]",
+			TupleType{Elems: []Type{
+				OptionalType{Elem: TupleType{Elems: []Type{TensorType{}, ListType{Elem: IntType{}}}}},
+				DictType{Key: StringType{}, Value: OptionalType{Elem: FloatType{}}},
+			}},
+		},
+	}
+
+	for _, c := range cases {
+		typ, err := ParseType(c.typ)
+		if err != nil {
+			t.Errorf("%s: %v", c.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(typ, c.expected) {
+			t.Errorf("%s: expected %v but got %v", c.typ, c.expected, typ)
+		}
+
+		// Types should parse back from their annotations
+		again, err := ParseType(typ.String())
+		if err != nil || !reflect.DeepEqual(again, typ) {
+			t.Errorf("%s: %s did not round trip (%v)", c.typ, typ, err)
+		}
+	}
+}
+
+func Test_ParseTypeInvalid(t *testing.T) {
+	for _, typ := range []string{"", "List[int", "Dict[str]", "Optional[int, float]", "(Tensor,", "int]", "Tensor foo", "Float(2", "Foo(2)", "Float(x)"} {
+		if _, err := ParseType(typ); err == nil {
+			t.Errorf("expected an error for %q", typ)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package torch
+
+import "fmt"
+
+// ArgumentError is returned when method inputs do not match the method schema
+type ArgumentError struct {
+	// Method is the name of the method
+	Method string
+	// Argument is the name of the offending argument (empty if too many arguments were given)
+	Argument string
+	// Type is the expected type of the argument (nil if the argument is not in the schema)
+	Type Type
+	// Reason describes the mismatch
+	Reason string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("method %s: %s", e.Method, e.Reason)
+}
+
+// Validate checks positional inputs against the method schema without running the method: the
+// number of inputs, the kind of each input and, for tensors annotated with a data type or rank
+// (e.g. by tracing), the data type and rank of tensors. Validation is opt-in as Run leaves the
+// checks to LibTorch. Errors are returned as *ArgumentError.
+func (m *JITModuleMethod) Validate(inputs ...interface{}) error {
+	return m.ValidateKwargs(inputs, nil)
+}
+
+// ValidateKwargs checks positional and keyword arguments against the method schema as
+// RunWithKwargs would receive them (see Validate)
+func (m *JITModuleMethod) ValidateKwargs(args []interface{}, kwargs map[string]interface{}) error {
+	arguments, err := m.bindArguments(args, kwargs)
+	if err != nil {
+		return err
+	}
+
+	for i, value := range args {
+		if err := m.checkArgument(arguments[i], value); err != nil {
+			return err
+		}
+	}
+
+	for _, arg := range arguments[len(args):] {
+		if value, ok := kwargs[arg.Name]; ok {
+			if err := m.checkArgument(arg, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// bindArguments checks that args and kwargs bind to the method arguments and returns the
+// arguments of the method without self
+func (m *JITModuleMethod) bindArguments(args []interface{}, kwargs map[string]interface{}) ([]JITModuleMethodArgument, error) {
+	arguments, err := m.ArgumentsE()
+	if err != nil {
+		return nil, err
+	}
+	if len(arguments) > 0 && arguments[0].Name == "self" {
+		arguments = arguments[1:]
+	}
+
+	positional := 0
+	for positional < len(arguments) && !arguments[positional].KwargOnly {
+		positional++
+	}
+	if len(args) > positional {
+		return nil, &ArgumentError{
+			Method: m.Name,
+			Reason: fmt.Sprintf("takes %d positional arguments but %d were given", positional, len(args)),
+		}
+	}
+
+	for name := range kwargs {
+		index := -1
+		for i, arg := range arguments {
+			if arg.Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, &ArgumentError{
+				Method:   m.Name,
+				Argument: name,
+				Reason:   "got an unexpected keyword argument " + name,
+			}
+		}
+		if index < len(args) {
+			return nil, &ArgumentError{
+				Method:   m.Name,
+				Argument: name,
+				Type:     arguments[index].TypeInfo,
+				Reason:   "got multiple values for argument " + name,
+			}
+		}
+	}
+
+	var missing []JITModuleMethodArgument
+	var missingNames []string
+	for _, arg := range arguments[len(args):] {
+		if _, ok := kwargs[arg.Name]; !ok && !arg.HasDefault {
+			missing = append(missing, arg)
+			missingNames = append(missingNames, arg.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ArgumentError{
+			Method:   m.Name,
+			Argument: missing[0].Name,
+			Type:     missing[0].TypeInfo,
+			Reason:   fmt.Sprintf("is missing arguments %v", missingNames),
+		}
+	}
+
+	return arguments, nil
+}
+
+func (m *JITModuleMethod) checkArgument(arg JITModuleMethodArgument, value interface{}) error {
+	if reason := checkValue(arg.TypeInfo, value); reason != "" {
+		return &ArgumentError{
+			Method:   m.Name,
+			Argument: arg.Name,
+			Type:     arg.TypeInfo,
+			Reason:   fmt.Sprintf("argument %s %s", arg.Name, reason),
+		}
+	}
+	return nil
+}
+
+// checkValue returns why value does not match typ (or an empty string if it does)
+func checkValue(typ Type, value interface{}) string {
+	mismatch := func() string {
+		if value == nil {
+			return fmt.Sprintf("expected %s but got nil", typ)
+		}
+		return fmt.Sprintf("expected %s but got %T", typ, value)
+	}
+
+	switch t := typ.(type) {
+	case TensorType:
+		tensor, ok := value.(*Tensor)
+		if !ok || tensor == nil {
+			return mismatch()
+		}
+		if t.DType != 0 && tensor.DType() != t.DType {
+			return fmt.Sprintf("expected a %s tensor but got %s", dtypeName(t.DType), dtypeName(tensor.DType()))
+		}
+		if t.Sizes != nil && len(tensor.Shape()) != len(t.Sizes) {
+			return fmt.Sprintf("expected a tensor of rank %d but got rank %d", len(t.Sizes), len(tensor.Shape()))
+		}
+	case IntType:
+		switch value.(type) {
+		case int, int64:
+		default:
+			return mismatch()
+		}
+	case FloatType:
+		if _, ok := value.(float64); !ok {
+			return mismatch()
+		}
+	case BoolType:
+		if _, ok := value.(bool); !ok {
+			return mismatch()
+		}
+	case NoneType:
+		if value != nil {
+			return mismatch()
+		}
+	case OptionalType:
+		if value != nil {
+			return checkValue(t.Elem, value)
+		}
+	case TupleType:
+		tuple, ok := value.(Tuple)
+		if !ok {
+			return mismatch()
+		}
+		if len(tuple) != len(t.Elems) {
+			return fmt.Sprintf("expected a tuple of %d elements but got %d", len(t.Elems), len(tuple))
+		}
+		for i, elem := range tuple {
+			if reason := checkValue(t.Elems[i], elem); reason != "" {
+				return fmt.Sprintf("element %d %s", i, reason)
+			}
+		}
+	case OtherType:
+		// Types without a Go representation are left for LibTorch to check
+	default:
+		return fmt.Sprintf("of type %s is not supported", typ)
+	}
+
+	return ""
+}
@@ -0,0 +1,96 @@
+package torch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Validate(t *testing.T) {
+	module, err := CompileTorchScript(`
+def pick(a, n: int, pair: Tuple[Tensor, float], scale: Optional[float] = None):
+	return a * n
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := module.GetMethod("pick")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := NewTensor([]float32{1, 2})
+	pair := Tuple{a, 1.0}
+
+	if err := method.Validate(a, 2, pair); err != nil {
+		t.Error(err)
+	}
+	if err := method.Validate(a, int64(2), pair, 0.5); err != nil {
+		t.Error(err)
+	}
+	if err := method.ValidateKwargs([]interface{}{a}, map[string]interface{}{"n": 2, "pair": pair}); err != nil {
+		t.Error(err)
+	}
+
+	cases := []struct {
+		args     []interface{}
+		kwargs   map[string]interface{}
+		argument string
+		msg      string
+	}{
+		{[]interface{}{a}, nil, "n", "is missing arguments [n pair]"},
+		{[]interface{}{a, 2, pair, nil, 1}, nil, "", "takes 4 positional arguments but 5 were given"},
+		{[]interface{}{a, 2, pair}, map[string]interface{}{"top_k": 1}, "top_k", "unexpected keyword argument top_k"},
+		{[]interface{}{2, 2, pair}, nil, "a", "argument a expected Tensor but got int"},
+		{[]interface{}{a, 2.0, pair}, nil, "n", "argument n expected int but got float64"},
+		{[]interface{}{a, nil, pair}, nil, "n", "argument n expected int but got nil"},
+		{[]interface{}{a, 2, Tuple{a}}, nil, "pair", "argument pair expected a tuple of 2 elements but got 1"},
+		{[]interface{}{a, 2, Tuple{a, 1}}, nil, "pair", "argument pair element 1 expected float but got int"},
+		{[]interface{}{a, 2, pair, true}, nil, "scale", "argument scale expected float but got bool"},
+		{[]interface{}{a, 2}, map[string]interface{}{"pair": a}, "pair", "argument pair expected Tuple[Tensor, float] but got *torch.Tensor"},
+	}
+
+	for _, c := range cases {
+		err := method.ValidateKwargs(c.args, c.kwargs)
+
+		var argErr *ArgumentError
+		if !errors.As(err, &argErr) {
+			t.Errorf("expected an ArgumentError containing %q but got %v", c.msg, err)
+			continue
+		}
+		if argErr.Method != "pick" || argErr.Argument != c.argument || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("expected an error for argument %q containing %q but got %#v", c.argument, c.msg, argErr)
+		}
+
+		// Validation should not be stricter than LibTorch
+		if _, err := method.RunWithKwargs(c.args, c.kwargs); err == nil {
+			t.Errorf("%q: expected running the method to fail", c.msg)
+		}
+	}
+}
+
+func Test_ValidateAnnotatedTensor(t *testing.T) {
+	typ := TensorType{DType: Float, Sizes: []int64{-1, 3}}
+
+	matrix, _ := NewTensor([][]float32{{1, 2, 3}})
+	if reason := checkValue(typ, matrix); reason != "" {
+		t.Error(reason)
+	}
+
+	// Only the rank is checked as traced sizes are those of the example inputs
+	wider, _ := NewTensor([][]float32{{1, 2, 3, 4}})
+	if reason := checkValue(typ, wider); reason != "" {
+		t.Error(reason)
+	}
+
+	long, _ := NewTensor([][]int64{{1, 2, 3}})
+	if reason := checkValue(typ, long); reason != "expected a Float tensor but got Long" {
+		t.Error("wrong reason", reason)
+	}
+
+	vector, _ := NewTensor([]float32{1, 2, 3})
+	if reason := checkValue(typ, vector); reason != "expected a tensor of rank 2 but got rank 1" {
+		t.Error("wrong reason", reason)
+	}
+}
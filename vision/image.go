@@ -0,0 +1,324 @@
+// Package vision converts images to and from tensors
+package vision
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+
+	"github.com/orktes/go-torch"
+)
+
+// Layout dimension order of image tensors
+type Layout int
+
+const (
+	// CHW channels, height, width (PyTorch models)
+	CHW Layout = iota
+	// HWC height, width, channels (image libraries, NumPy)
+	HWC
+)
+
+// Options options for converting an image to a tensor
+type Options struct {
+	// Layout dimension order of the tensor (CHW by default)
+	Layout Layout
+	// DType torch.Float (default) for values in [0, 1] or torch.Byte for values in [0, 255]
+	DType torch.DType
+	// Width and Height resize the image before cropping (zero keeps the original size)
+	Width, Height int
+	// CropWidth and CropHeight center crop the image after resizing (zero disables cropping)
+	CropWidth, CropHeight int
+	// Mean and Std normalize each channel of float tensors as (x - mean) / std (Mean defaults
+	// to 0 and Std to 1 if only one of them is set)
+	Mean, Std []float32
+}
+
+// ImageNetMean and ImageNetStd are the normalization values used by most torchvision models
+var (
+	ImageNetMean = []float32{0.485, 0.456, 0.406}
+	ImageNetStd  = []float32{0.229, 0.224, 0.225}
+)
+
+// ToTensor converts an image to a 3 channel (RGB) tensor
+func ToTensor(img image.Image, opts Options) (*torch.Tensor, error) {
+	if opts.DType == 0 {
+		opts.DType = torch.Float
+	}
+
+	if opts.Width > 0 && opts.Height > 0 {
+		img = Resize(img, opts.Width, opts.Height)
+	}
+	if opts.CropWidth > 0 && opts.CropHeight > 0 {
+		img = CenterCrop(img, opts.CropWidth, opts.CropHeight)
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	shape := []int64{3, int64(h), int64(w)}
+	if opts.Layout == HWC {
+		shape = []int64{int64(h), int64(w), 3}
+	}
+
+	switch opts.DType {
+	case torch.Byte:
+		data := make([]uint8, 3*w*h)
+		forEachPixel(img, opts.Layout, func(i, c int, v uint8) {
+			data[i] = v
+		})
+		return torch.NewTensorWithShape(data, shape, torch.Byte)
+	case torch.Float:
+		data := Pixels(img, opts.Layout)
+		if opts.Mean != nil || opts.Std != nil {
+			// Mean defaults to 0 and Std to 1 when only one of them is given
+			mean, std := opts.Mean, opts.Std
+			if mean == nil {
+				mean = []float32{0, 0, 0}
+			}
+			if std == nil {
+				std = []float32{1, 1, 1}
+			}
+			if err := Normalize(data, opts.Layout, mean, std); err != nil {
+				return nil, err
+			}
+		}
+		return torch.NewTensorWithShape(data, shape, torch.Float)
+	default:
+		return nil, fmt.Errorf("unsupported DType %d for image tensors", int(opts.DType))
+	}
+}
+
+// Pixels returns the RGB values of an image scaled to [0, 1] in given layout
+func Pixels(img image.Image, layout Layout) []float32 {
+	bounds := img.Bounds()
+	data := make([]float32, 3*bounds.Dx()*bounds.Dy())
+	forEachPixel(img, layout, func(i, c int, v uint8) {
+		data[i] = float32(v) / 255
+	})
+
+	return data
+}
+
+// Normalize normalizes each channel of RGB values in given layout as (x - mean) / std
+func Normalize(data []float32, layout Layout, mean, std []float32) error {
+	if len(mean) != 3 || len(std) != 3 {
+		return fmt.Errorf("mean and std should have a value for each of the 3 channels")
+	}
+
+	plane := len(data) / 3
+	for i := range data {
+		c := i % 3
+		if layout == CHW {
+			c = i / plane
+		}
+		data[i] = (data[i] - mean[c]) / std[c]
+	}
+
+	return nil
+}
+
+// forEachPixel calls fn for every channel value of every pixel with the index of the value in given layout
+func forEachPixel(img image.Image, layout Layout, fn func(i, c int, v uint8)) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	plane := w * h
+
+	index := func(x, y, c int) int {
+		if layout == HWC {
+			return (y*w+x)*3 + c
+		}
+		return c*plane + y*w + x
+	}
+
+	// Values are read as non-premultiplied colors (as color.NRGBAModel converts them) whatever the image type
+	switch src := img.(type) {
+	case *image.NRGBA:
+		for y := 0; y < h; y++ {
+			row := src.Pix[(y+bounds.Min.Y-src.Rect.Min.Y)*src.Stride:]
+			for x := 0; x < w; x++ {
+				p := row[(x+bounds.Min.X-src.Rect.Min.X)*4:]
+				fn(index(x, y, 0), 0, p[0])
+				fn(index(x, y, 1), 1, p[1])
+				fn(index(x, y, 2), 2, p[2])
+			}
+		}
+	case *image.RGBA:
+		for y := 0; y < h; y++ {
+			row := src.Pix[(y+bounds.Min.Y-src.Rect.Min.Y)*src.Stride:]
+			for x := 0; x < w; x++ {
+				p := row[(x+bounds.Min.X-src.Rect.Min.X)*4:]
+				fn(index(x, y, 0), 0, unpremultiply(p[0], p[3]))
+				fn(index(x, y, 1), 1, unpremultiply(p[1], p[3]))
+				fn(index(x, y, 2), 2, unpremultiply(p[2], p[3]))
+			}
+		}
+	case *image.Gray:
+		for y := 0; y < h; y++ {
+			row := src.Pix[(y+bounds.Min.Y-src.Rect.Min.Y)*src.Stride:]
+			for x := 0; x < w; x++ {
+				v := row[x+bounds.Min.X-src.Rect.Min.X]
+				fn(index(x, y, 0), 0, v)
+				fn(index(x, y, 1), 1, v)
+				fn(index(x, y, 2), 2, v)
+			}
+		}
+	default:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := color.NRGBAModel.Convert(img.At(x+bounds.Min.X, y+bounds.Min.Y)).(color.NRGBA)
+				fn(index(x, y, 0), 0, c.R)
+				fn(index(x, y, 1), 1, c.G)
+				fn(index(x, y, 2), 2, c.B)
+			}
+		}
+	}
+}
+
+// unpremultiply converts an alpha-premultiplied value to a non-premultiplied value as color.NRGBAModel does
+func unpremultiply(v, a uint8) uint8 {
+	switch a {
+	case 0xff:
+		return v
+	case 0:
+		return 0
+	}
+	return uint8((uint32(v) * 0x101 * 0xffff / (uint32(a) * 0x101)) >> 8)
+}
+
+// ToImage converts a tensor to an image. Tensors of shape (H, W) or with a single channel are
+// converted to grayscale images (e.g. segmentation masks) and tensors with 3 or 4 channels to RGBA
+// images. Float tensors are expected to have values in [0, 1], other tensors values in [0, 255].
+// Values outside the range are clamped.
+func ToImage(t *torch.Tensor, layout Layout) (image.Image, error) {
+	shape := t.Shape()
+
+	var c, h, w int
+	switch {
+	case len(shape) == 2:
+		c, h, w = 1, int(shape[0]), int(shape[1])
+	case len(shape) == 3 && layout == CHW:
+		c, h, w = int(shape[0]), int(shape[1]), int(shape[2])
+	case len(shape) == 3 && layout == HWC:
+		h, w, c = int(shape[0]), int(shape[1]), int(shape[2])
+	default:
+		return nil, fmt.Errorf("unsupported image tensor shape %v", shape)
+	}
+
+	if c != 1 && c != 3 && c != 4 {
+		return nil, fmt.Errorf("unsupported number of channels %d", c)
+	}
+
+	values, err := flatValues(t)
+	if err != nil {
+		return nil, err
+	}
+
+	at := func(x, y, ch int) uint8 {
+		if len(shape) == 2 {
+			return values[y*w+x]
+		}
+		if layout == HWC {
+			return values[(y*w+x)*c+ch]
+		}
+		return values[ch*w*h+y*w+x]
+	}
+
+	rect := image.Rect(0, 0, w, h)
+	if c == 1 {
+		img := image.NewGray(rect)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.Pix[y*img.Stride+x] = at(x, y, 0)
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA(rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[y*img.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = at(x, y, 0), at(x, y, 1), at(x, y, 2), 255
+			if c == 4 {
+				p[3] = at(x, y, 3)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// flatValues returns tensor values as bytes in row-major order
+func flatValues(t *torch.Tensor) ([]uint8, error) {
+	var values []uint8
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		switch v := v.(type) {
+		case []float32:
+			for _, f := range v {
+				values = append(values, clamp(float64(f)*255))
+			}
+		case []float64:
+			for _, f := range v {
+				values = append(values, clamp(f*255))
+			}
+		case []uint8:
+			values = append(values, v...)
+		case []int8:
+			for _, i := range v {
+				values = append(values, clamp(float64(i)))
+			}
+		case []int32:
+			for _, i := range v {
+				values = append(values, clamp(float64(i)))
+			}
+		case []int64:
+			for _, i := range v {
+				values = append(values, clamp(float64(i)))
+			}
+		default:
+			rv := reflectSlice(v)
+			if rv == nil {
+				return fmt.Errorf("unsupported tensor value %T", v)
+			}
+			for _, e := range rv {
+				if err := walk(e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(t.Value()); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func clamp(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+func reflectSlice(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+
+	res := make([]interface{}, rv.Len())
+	for i := range res {
+		res[i] = rv.Index(i).Interface()
+	}
+
+	return res
+}
@@ -0,0 +1,174 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/orktes/go-torch"
+)
+
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 50), G: uint8(y * 100), B: 255, A: 255})
+		}
+	}
+	return img
+}
+
+func Test_PixelsColorModel(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := range nrgba.Pix {
+		nrgba.Pix[i] = uint8(i * 7)
+	}
+
+	// Translucent colors are stored premultiplied in RGBA images
+	rgba := image.NewRGBA(nrgba.Rect)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			rgba.Set(x, y, nrgba.At(x, y))
+		}
+	}
+
+	for _, img := range []image.Image{nrgba, rgba} {
+		// Wrapping hides the concrete type so that pixels are read through the generic path
+		expected := Pixels(struct{ image.Image }{img}, HWC)
+		pixels := Pixels(img, HWC)
+		for i := range expected {
+			if pixels[i] != expected[i] {
+				t.Fatalf("%T: wrong value at %d: %v (expected %v)", img, i, pixels[i], expected[i])
+			}
+		}
+	}
+}
+
+func Test_ToTensorCHW(t *testing.T) {
+	tensor, err := ToTensor(testImage(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tensor.DType() != torch.Float {
+		t.Error("should be a float tensor")
+	}
+
+	shape := tensor.Shape()
+	if shape[0] != 3 || shape[1] != 2 || shape[2] != 4 {
+		t.Fatal("wrong shape", shape)
+	}
+
+	val := tensor.Value().([][][]float32)
+	if math.Abs(float64(val[0][0][1])-50.0/255) > 1e-6 {
+		t.Error("wrong red value", val[0][0][1])
+	}
+	if math.Abs(float64(val[1][1][0])-100.0/255) > 1e-6 {
+		t.Error("wrong green value", val[1][1][0])
+	}
+	if val[2][1][3] != 1 {
+		t.Error("wrong blue value", val[2][1][3])
+	}
+}
+
+func Test_ToTensorNormalizedHWC(t *testing.T) {
+	tensor, err := ToTensor(testImage(), Options{
+		Layout: HWC,
+		Mean:   []float32{0.5, 0.5, 0.5},
+		Std:    []float32{0.5, 0.5, 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shape := tensor.Shape()
+	if shape[0] != 2 || shape[1] != 4 || shape[2] != 3 {
+		t.Fatal("wrong shape", shape)
+	}
+
+	val := tensor.Value().([][][]float32)
+	if val[0][0][0] != -1 || val[0][0][2] != 1 {
+		t.Error("wrong normalized value", val[0][0])
+	}
+}
+
+func Test_ToTensorMeanOnly(t *testing.T) {
+	tensor, err := ToTensor(testImage(), Options{
+		Layout: HWC,
+		Mean:   []float32{0.5, 0.5, 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val := tensor.Value().([][][]float32)
+	if val[0][0][0] != -0.5 || val[0][0][2] != 0.5 {
+		t.Error("std should default to 1", val[0][0])
+	}
+}
+
+func Test_ToTensorResizeAndCrop(t *testing.T) {
+	tensor, err := ToTensor(testImage(), Options{
+		DType:      torch.Byte,
+		Width:      8,
+		Height:     4,
+		CropWidth:  2,
+		CropHeight: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shape := tensor.Shape()
+	if shape[0] != 3 || shape[1] != 2 || shape[2] != 2 {
+		t.Fatal("wrong shape", shape)
+	}
+
+	if tensor.DType() != torch.Byte {
+		t.Error("should be a byte tensor")
+	}
+}
+
+func Test_ToImage(t *testing.T) {
+	src := testImage()
+	tensor, err := ToTensor(src, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := ToImage(tensor, CHW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != src.Bounds() {
+		t.Fatal("wrong bounds", img.Bounds())
+	}
+
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if img.At(x, y) != src.At(x, y) {
+				t.Errorf("wrong color at %d,%d: %v", x, y, img.At(x, y))
+			}
+		}
+	}
+}
+
+func Test_ToImageMask(t *testing.T) {
+	mask, _ := torch.NewTensor([][]int64{{0, 1}, {2, 300}})
+
+	img, err := ToImage(mask, CHW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("masks should be converted to gray images (got %T)", img)
+	}
+
+	if gray.GrayAt(1, 0).Y != 1 || gray.GrayAt(1, 1).Y != 255 {
+		t.Error("wrong mask values", gray.Pix)
+	}
+}
@@ -0,0 +1,86 @@
+package vision
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Resize resizes an image to given size using bilinear interpolation
+func Resize(img image.Image, width, height int) image.Image {
+	src := toRGBA(img)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if sw == width && sh == height {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+
+	scaleX := float64(sw) / float64(width)
+	scaleY := float64(sh) / float64(height)
+
+	for y := 0; y < height; y++ {
+		// Pixel centers are aligned (align_corners=False)
+		fy := math.Max((float64(y)+0.5)*scaleY-0.5, 0)
+		y0 := int(fy)
+		y1 := minInt(y0+1, sh-1)
+		wy := fy - float64(y0)
+
+		for x := 0; x < width; x++ {
+			fx := math.Max((float64(x)+0.5)*scaleX-0.5, 0)
+			x0 := int(fx)
+			x1 := minInt(x0+1, sw-1)
+			wx := fx - float64(x0)
+
+			p00 := src.Pix[y0*src.Stride+x0*4:]
+			p01 := src.Pix[y0*src.Stride+x1*4:]
+			p10 := src.Pix[y1*src.Stride+x0*4:]
+			p11 := src.Pix[y1*src.Stride+x1*4:]
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for c := 0; c < 4; c++ {
+				top := float64(p00[c])*(1-wx) + float64(p01[c])*wx
+				bottom := float64(p10[c])*(1-wx) + float64(p11[c])*wx
+				d[c] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+
+	return dst
+}
+
+// CenterCrop crops given size from the center of an image. If the image is smaller than the
+// crop size it is padded with zeros.
+func CenterCrop(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	x0 := bounds.Min.X + (bounds.Dx()-width)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-height)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Rect, img, image.Pt(x0, y0), draw.Src)
+
+	return dst
+}
+
+// toRGBA returns img as *image.RGBA with bounds starting at (0, 0)
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+
+	return rgba
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,186 @@
+// Package transforms provides composable image preprocessing pipelines producing batched tensors
+package transforms
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/orktes/go-torch"
+	"github.com/orktes/go-torch/vision"
+)
+
+// Sample is an image going through a pipeline. Image is set until ToTensor is applied after
+// which Data contains the sample as CHW float values.
+type Sample struct {
+	Image image.Image
+
+	Data     []float32
+	Channels int
+	Height   int
+	Width    int
+}
+
+// Transform transforms a single sample
+type Transform interface {
+	Apply(s *Sample) error
+}
+
+// TransformFunc adapts a function to the Transform interface
+type TransformFunc func(s *Sample) error
+
+// Apply calls f(s)
+func (f TransformFunc) Apply(s *Sample) error {
+	return f(s)
+}
+
+// Resize resizes the image to given size
+func Resize(width, height int) Transform {
+	return TransformFunc(func(s *Sample) error {
+		if s.Image == nil {
+			return fmt.Errorf("resize must be applied before ToTensor")
+		}
+		s.Image = vision.Resize(s.Image, width, height)
+		return nil
+	})
+}
+
+// CenterCrop crops given size from the center of the image
+func CenterCrop(width, height int) Transform {
+	return TransformFunc(func(s *Sample) error {
+		if s.Image == nil {
+			return fmt.Errorf("center crop must be applied before ToTensor")
+		}
+		s.Image = vision.CenterCrop(s.Image, width, height)
+		return nil
+	})
+}
+
+// ToTensor converts the image to CHW float values in [0, 1]
+func ToTensor() Transform {
+	return TransformFunc(func(s *Sample) error {
+		if s.Image == nil {
+			return fmt.Errorf("sample has no image to convert")
+		}
+		bounds := s.Image.Bounds()
+		s.Data = vision.Pixels(s.Image, vision.CHW)
+		s.Channels, s.Height, s.Width = 3, bounds.Dy(), bounds.Dx()
+		s.Image = nil
+		return nil
+	})
+}
+
+// Normalize normalizes each channel as (x - mean) / std
+func Normalize(mean, std []float32) Transform {
+	return TransformFunc(func(s *Sample) error {
+		if s.Data == nil {
+			return fmt.Errorf("normalize must be applied after ToTensor")
+		}
+		return vision.Normalize(s.Data, vision.CHW, mean, std)
+	})
+}
+
+// Pipeline applies transforms to images using a bounded pool of goroutines
+type Pipeline struct {
+	Transforms []Transform
+	// Workers maximum number of images processed concurrently (defaults to runtime.NumCPU())
+	Workers int
+}
+
+// Compose returns a pipeline applying given transforms in order
+func Compose(transforms ...Transform) *Pipeline {
+	return &Pipeline{Transforms: transforms}
+}
+
+// Apply runs all transforms for a single image
+func (p *Pipeline) Apply(img image.Image) (*Sample, error) {
+	s := &Sample{Image: img}
+	for _, t := range p.Transforms {
+		if err := t.Apply(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Data == nil {
+		if err := ToTensor().Apply(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Batch transforms images concurrently and stacks them into a single (N, C, H, W) float tensor.
+// All images must have the same size after the transforms.
+func (p *Pipeline) Batch(images []image.Image) (*torch.Tensor, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images to batch")
+	}
+
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(images) {
+		workers = len(images)
+	}
+
+	samples := make([]*Sample, len(images))
+	errs := make([]error, len(images))
+
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				samples[index], errs[index] = p.Apply(images[index])
+			}
+		}()
+	}
+
+	for i := range images {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+	}
+
+	first := samples[0]
+	size := len(first.Data)
+	data := make([]float32, size*len(samples))
+	for i, s := range samples {
+		if s.Channels != first.Channels || s.Height != first.Height || s.Width != first.Width {
+			return nil, fmt.Errorf(
+				"image %d has size %dx%dx%d but expected %dx%dx%d",
+				i, s.Channels, s.Height, s.Width, first.Channels, first.Height, first.Width,
+			)
+		}
+		copy(data[i*size:], s.Data)
+	}
+
+	shape := []int64{int64(len(samples)), int64(first.Channels), int64(first.Height), int64(first.Width)}
+
+	// The values are passed as bytes in native byte order as they are laid out in memory (through a
+	// slice header as batches can be larger than fixed size array casts can address)
+	var buf []byte
+	if len(data) > 0 {
+		hdr := (*reflect.SliceHeader)(unsafe.Pointer(&buf))
+		hdr.Data = uintptr(unsafe.Pointer(&data[0]))
+		hdr.Len = 4 * len(data)
+		hdr.Cap = 4 * len(data)
+	}
+
+	tensor, err := torch.NewTensorFromBytes(buf, shape, torch.Float)
+	runtime.KeepAlive(data)
+	return tensor, err
+}
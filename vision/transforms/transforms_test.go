@@ -0,0 +1,80 @@
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/orktes/go-torch/vision"
+)
+
+func testImages(n int, width, height int) []image.Image {
+	images := make([]image.Image, n)
+	for i := range images {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, color.RGBA{R: uint8(i), G: 128, B: 255, A: 255})
+			}
+		}
+		images[i] = img
+	}
+	return images
+}
+
+func Test_PipelineBatch(t *testing.T) {
+	pipeline := Compose(
+		Resize(32, 32),
+		CenterCrop(16, 16),
+		ToTensor(),
+		Normalize(vision.ImageNetMean, vision.ImageNetStd),
+	)
+	pipeline.Workers = 3
+
+	batch, err := pipeline.Batch(testImages(10, 64, 48))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shape := batch.Shape()
+	if shape[0] != 10 || shape[1] != 3 || shape[2] != 16 || shape[3] != 16 {
+		t.Fatal("wrong batch shape", shape)
+	}
+
+	val := batch.Value().([][][][]float32)
+	expected := (float32(9)/255 - vision.ImageNetMean[0]) / vision.ImageNetStd[0]
+	if val[9][0][0][0] != expected {
+		t.Error("samples should be stacked in order", val[9][0][0][0], expected)
+	}
+}
+
+func Test_PipelineBatchSizeMismatch(t *testing.T) {
+	images := append(testImages(1, 8, 8), testImages(1, 4, 4)...)
+
+	if _, err := Compose(ToTensor()).Batch(images); err == nil {
+		t.Error("should return an error for images of different size")
+	}
+}
+
+func Test_PipelineTransformOrder(t *testing.T) {
+	_, err := Compose(ToTensor(), Resize(4, 4)).Batch(testImages(1, 8, 8))
+	if err == nil {
+		t.Error("should return an error when resizing after ToTensor")
+	}
+}
+
+func Benchmark_PipelineBatch(b *testing.B) {
+	images := testImages(32, 256, 256)
+	pipeline := Compose(
+		Resize(224, 224),
+		ToTensor(),
+		Normalize(vision.ImageNetMean, vision.ImageNetStd),
+	)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pipeline.Batch(images); err != nil {
+			b.Fatal(err)
+		}
+	}
+}